@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/CedricElie/etcd-walker/walker"
+)
+
+// alarmRecord is an alarm listed or disarmed by the alarm command.
+type alarmRecord struct {
+	walker.Alarm
+	Disarmed bool `json:"disarmed"`
+}
+
+func (r alarmRecord) Columns() []string { return []string{"MEMBER", "ALARM", "DISARMED"} }
+func (r alarmRecord) Row() []string {
+	return []string{strconv.FormatUint(r.MemberID, 16), r.Alarm.Alarm, strconv.FormatBool(r.Disarmed)}
+}
+func (r alarmRecord) Text(color bool) string {
+	if r.Disarmed {
+		return fmt.Sprintf("Disarmed %s on member %x", r.Alarm.Alarm, r.MemberID)
+	}
+	return fmt.Sprintf("%s on member %x", r.Alarm.Alarm, r.MemberID)
+}
+
+func alarmCommand() *command {
+	c := newCommand("alarm", "ls | disarm", "List the cluster's alarms, or disarm them once the cause is fixed", 1, 1)
+	c.run = func(ctx context.Context, e *env, args []string) error {
+		if args[0] != "ls" && args[0] != "disarm" {
+			return usagef("unknown alarm command %q, want ls or disarm", args[0])
+		}
+		cli, err := e.client()
+		if err != nil {
+			return err
+		}
+		out, err := e.output()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := e.timeout(ctx)
+		defer cancel()
+		disarm := args[0] == "disarm"
+		var list []walker.Alarm
+		if disarm {
+			list, err = walker.DisarmAlarms(ctx, cli)
+		} else {
+			list, err = walker.Alarms(ctx, cli)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to %s alarms: %w", args[0], err)
+		}
+
+		if len(list) == 0 {
+			log.Printf("No alarms")
+		}
+		for _, a := range list {
+			if disarm && a.Alarm == "NOSPACE" {
+				log.Printf("NOSPACE comes back if the database is still over its quota; compact and defrag first")
+			}
+			if err := out.Write(alarmRecord{Alarm: a, Disarmed: disarm}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return c
+}
@@ -0,0 +1,267 @@
+// Package commands implements the etcd-walker subcommands.
+package commands
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/CedricElie/etcd-walker/output"
+	"github.com/CedricElie/etcd-walker/walker"
+)
+
+// Exit codes returned by Run.
+const (
+	ExitOK    = 0
+	ExitError = 1 // The command ran and failed
+	ExitUsage = 2 // Bad command line
+)
+
+// command is one etcd-walker subcommand. Constructors create the flag set
+// and capture the flag variables in run.
+type command struct {
+	name    string
+	args    string // Positional argument synopsis for usage
+	summary string
+	minArgs int
+	maxArgs int // -1 for no limit
+	flags   *flag.FlagSet
+	offline bool // Also reads from --snapshot
+	run     func(ctx context.Context, e *env, args []string) error
+}
+
+func newCommand(name, args, summary string, minArgs, maxArgs int) *command {
+	return &command{
+		name:    name,
+		args:    args,
+		summary: summary,
+		minArgs: minArgs,
+		maxArgs: maxArgs,
+		flags:   flag.NewFlagSet(name, flag.ContinueOnError),
+	}
+}
+
+// commandList returns every subcommand, in the order help lists them.
+func commandList() []*command {
+	return []*command{
+		lsCommand(),
+		getCommand(),
+		putCommand(),
+		editCommand(),
+		cpCommand(),
+		grepCommand(),
+		treeCommand(),
+		duCommand(),
+		watchCommand(),
+		historyCommand(),
+		diffCommand(),
+		mvCommand(),
+		rmCommand(),
+		restoreCommand(),
+		shellCommand(),
+		uiCommand(),
+		leaseCommand(),
+		statusCommand(),
+		alarmCommand(),
+		compactCommand(),
+		defragCommand(),
+		snapshotCommand(),
+	}
+}
+
+// globals are the connection and output flags shared by every command.
+type globals struct {
+	endpoints      string
+	context        string
+	dialTimeout    time.Duration
+	commandTimeout time.Duration
+	out            string
+	format         string
+	snapshot       string
+}
+
+func (g *globals) register(fs *flag.FlagSet) {
+	fs.StringVar(&g.endpoints, "endpoints", "", "Comma-separated etcd endpoints, overriding the config file")
+	fs.StringVar(&g.context, "context", "", "Cluster to use from the config file's contexts")
+	fs.DurationVar(&g.dialTimeout, "dial-timeout", walker.DefaultDialTimeout, "Timeout for connecting to etcd")
+	fs.DurationVar(&g.commandTimeout, "command-timeout", 5*time.Second, "Timeout for single requests")
+	fs.StringVar(&g.out, "out", "-", "Output file, - for stdout")
+	fs.StringVar(&g.format, "format", string(output.Text), fmt.Sprintf("Output format, one of %v", output.Formats))
+	fs.StringVar(&g.snapshot, "snapshot", "", "Read from a snapshot file or a member's member/snap/db instead of a cluster (ls, get, grep, tree and du); its whole history is read once to index the keys")
+}
+
+// usageError is a bad command line; Run exits with ExitUsage for it.
+type usageError struct{ msg string }
+
+func (e *usageError) Error() string { return e.msg }
+
+func usagef(format string, a ...any) error {
+	return &usageError{fmt.Sprintf(format, a...)}
+}
+
+// Run executes the command line (without the program name) and returns the
+// process exit code.
+func Run(args []string) int {
+	g := &globals{}
+	gfs := flag.NewFlagSet("etcd-walker", flag.ContinueOnError)
+	gfs.SetOutput(io.Discard)
+	g.register(gfs)
+
+	cmds := commandList()
+
+	if err := gfs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			printUsage(os.Stdout, gfs, cmds)
+			return ExitOK
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		printUsage(os.Stderr, gfs, cmds)
+		return ExitUsage
+	}
+
+	rest := gfs.Args()
+	if len(rest) == 0 {
+		printUsage(os.Stderr, gfs, cmds)
+		return ExitUsage
+	}
+
+	name := rest[0]
+	if name == "help" {
+		if len(rest) > 1 {
+			if c := findCommand(cmds, rest[1]); c != nil {
+				printCommandUsage(os.Stdout, c, gfs)
+				return ExitOK
+			}
+		}
+		printUsage(os.Stdout, gfs, cmds)
+		return ExitOK
+	}
+
+	c := findCommand(cmds, name)
+	if c == nil {
+		fmt.Fprintf(os.Stderr, "Error: unknown command %q\n", name)
+		printUsage(os.Stderr, gfs, cmds)
+		return ExitUsage
+	}
+
+	// Global flags are also accepted after the command name
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	c.flags.VisitAll(func(f *flag.Flag) { fs.Var(f.Value, f.Name, f.Usage) })
+	gfs.VisitAll(func(f *flag.Flag) { fs.Var(f.Value, f.Name, f.Usage) })
+
+	positional, err := parseInterspersed(fs, rest[1:])
+	if err == nil {
+		err = checkArgs(c, positional)
+	}
+	if err == nil {
+		if _, ferr := output.ParseFormat(g.format); ferr != nil {
+			err = usagef("%v", ferr)
+		}
+	}
+	if err == nil && g.snapshot != "" && !c.offline {
+		err = usagef("%s needs a cluster, only ls, get, grep, tree and du read from --snapshot", c.name)
+	}
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			printCommandUsage(os.Stdout, c, gfs)
+			return ExitOK
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		printCommandUsage(os.Stderr, c, gfs)
+		return ExitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	e := &env{g: g}
+	err = c.run(ctx, e, positional)
+	if cerr := e.close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		var uerr *usageError
+		if errors.As(err, &uerr) {
+			return ExitUsage
+		}
+		return ExitError
+	}
+	return ExitOK
+}
+
+func findCommand(cmds []*command, name string) *command {
+	for _, c := range cmds {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func checkArgs(c *command, args []string) error {
+	if len(args) < c.minArgs {
+		return usagef("%s needs %s", c.name, c.args)
+	}
+	if c.maxArgs >= 0 && len(args) > c.maxArgs {
+		return usagef("too many arguments for %s", c.name)
+	}
+	return nil
+}
+
+// parseInterspersed parses fs allowing flags after positional arguments, so
+// that "tree /registry --depth 2" works. Everything after "--" is positional.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		consumed := len(args) - len(rest)
+		if consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+func printUsage(w io.Writer, gfs *flag.FlagSet, cmds []*command) {
+	fmt.Fprintln(w, "Usage: etcd-walker [global flags] <command> [flags] [args]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, c := range cmds {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w, "\nGlobal flags:")
+	printDefaults(w, gfs)
+	fmt.Fprintln(w, "\nRun 'etcd-walker <command> --help' for the command's flags.")
+	fmt.Fprintf(w, "Exit codes: %d success, %d failure, %d bad usage.\n", ExitOK, ExitError, ExitUsage)
+}
+
+func printCommandUsage(w io.Writer, c *command, gfs *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: etcd-walker %s [flags] %s\n\n%s\n", c.name, c.args, c.summary)
+	hasFlags := false
+	c.flags.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		fmt.Fprintln(w, "\nFlags:")
+		printDefaults(w, c.flags)
+	}
+	fmt.Fprintln(w, "\nGlobal flags:")
+	printDefaults(w, gfs)
+}
+
+func printDefaults(w io.Writer, fs *flag.FlagSet) {
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+	fs.SetOutput(io.Discard)
+}
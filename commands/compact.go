@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/CedricElie/etcd-walker/walker"
+)
+
+// sizeRecord is how a member's database changed across compact or defrag.
+type sizeRecord struct {
+	Endpoint    string        `json:"endpoint"`
+	MemberID    uint64        `json:"member_id"`
+	SizeBefore  int64         `json:"db_size_before"`
+	SizeAfter   int64         `json:"db_size_after"`
+	InUseBefore int64         `json:"db_size_in_use_before"`
+	InUseAfter  int64         `json:"db_size_in_use_after"`
+	Took        time.Duration `json:"took_ns,omitempty"`
+}
+
+func newSizeRecord(before, after walker.EndpointStatus) sizeRecord {
+	return sizeRecord{
+		Endpoint:    after.Endpoint,
+		MemberID:    after.MemberID,
+		SizeBefore:  before.DBSize,
+		SizeAfter:   after.DBSize,
+		InUseBefore: before.DBSizeInUse,
+		InUseAfter:  after.DBSizeInUse,
+	}
+}
+
+func (r sizeRecord) Columns() []string {
+	return []string{"ENDPOINT", "ID", "DB_SIZE_BEFORE", "DB_SIZE_AFTER", "IN_USE_BEFORE", "IN_USE_AFTER", "TOOK"}
+}
+func (r sizeRecord) Row() []string {
+	return []string{r.Endpoint, strconv.FormatUint(r.MemberID, 16), humanBytes(r.SizeBefore), humanBytes(r.SizeAfter),
+		humanBytes(r.InUseBefore), humanBytes(r.InUseAfter), r.Took.Round(time.Millisecond).String()}
+}
+func (r sizeRecord) Text(color bool) string {
+	s := fmt.Sprintf("%s %x: db %s -> %s, in use %s -> %s", r.Endpoint, r.MemberID,
+		humanBytes(r.SizeBefore), humanBytes(r.SizeAfter), humanBytes(r.InUseBefore), humanBytes(r.InUseAfter))
+	if r.Took > 0 {
+		s += fmt.Sprintf(", took %s", r.Took.Round(time.Millisecond))
+	}
+	return s
+}
+
+// sizesAfter pairs the members of two Status results by endpoint.
+func sizesAfter(before, after *walker.ClusterStatus) []sizeRecord {
+	byEndpoint := make(map[string]walker.EndpointStatus)
+	for _, m := range before.Members {
+		byEndpoint[m.Endpoint] = m
+	}
+	var records []sizeRecord
+	for _, m := range after.Members {
+		if m.Endpoint != "" {
+			records = append(records, newSizeRecord(byEndpoint[m.Endpoint], m))
+		}
+	}
+	return records
+}
+
+// compactRecord is what compact did, or would do without --yes.
+type compactRecord struct {
+	Revision int64 `json:"revision"`
+	Compact  int64 `json:"compact_revision"`
+	Freed    int64 `json:"freed,omitempty"` // Most space a member freed for defrag to reclaim
+	DryRun   bool  `json:"dry_run,omitempty"`
+}
+
+func (r compactRecord) Columns() []string {
+	return []string{"REVISION", "COMPACT_REVISION", "FREED", "DRY_RUN"}
+}
+func (r compactRecord) Row() []string {
+	return []string{strconv.FormatInt(r.Revision, 10), strconv.FormatInt(r.Compact, 10), humanBytes(r.Freed), strconv.FormatBool(r.DryRun)}
+}
+func (r compactRecord) Text(color bool) string {
+	if r.DryRun {
+		return fmt.Sprintf("Would discard the history before revision %d, keeping %d revisions up to %d (use --yes to compact)",
+			r.Compact, r.Revision-r.Compact+1, r.Revision)
+	}
+	return fmt.Sprintf("Compacted at revision %d, keeping %d revisions up to %d; up to %s freed in the database, run 'etcd-walker defrag' to give it back to the filesystem",
+		r.Compact, r.Revision-r.Compact+1, r.Revision, humanBytes(r.Freed))
+}
+
+func compactCommand() *command {
+	c := newCommand("compact", "", "Discard old revisions once the cluster is healthy, reporting the space freed", 0, 0)
+	keep := c.flags.Int64("keep-revisions", 0, "Keep this many of the latest revisions")
+	olderThan := c.flags.Int64("older-than-rev", 0, "Discard the revisions older than this one")
+	yes := c.flags.Bool("yes", false, "Actually compact; without it compact only shows what it would discard")
+	useEndpoints := c.flags.Bool("use-endpoints", false, "Check the configured endpoints instead of each member's advertised client URL")
+	timeout := c.flags.Duration("timeout", 10*time.Minute, "Timeout for the compaction to be applied on every member")
+
+	c.run = func(ctx context.Context, e *env, args []string) error {
+		if (*keep > 0) == (*olderThan > 0) {
+			return usagef("give one of --keep-revisions or --older-than-rev, greater than zero")
+		}
+		cli, err := e.client()
+		if err != nil {
+			return err
+		}
+		out, err := e.output()
+		if err != nil {
+			return err
+		}
+
+		before, err := checkHealthy(ctx, e, cli, *useEndpoints, "before compacting")
+		if err != nil {
+			return err
+		}
+		rctx, cancel := e.timeout(ctx)
+		rev, err := walker.Revision(rctx, cli)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to read the current revision: %w", err)
+		}
+		target := *olderThan
+		if *keep > 0 {
+			target = rev - *keep + 1
+		}
+		if target > rev {
+			return usagef("--older-than-rev %d is after the current revision %d", target, rev)
+		}
+		if target <= 1 {
+			return fmt.Errorf("nothing to compact, the current revision is %d", rev)
+		}
+
+		record := compactRecord{Revision: rev, Compact: target, DryRun: !*yes}
+		if !*yes {
+			return out.Write(record)
+		}
+
+		log.Printf("Compacting at revision %d...", target)
+		cctx, cancel := context.WithTimeout(ctx, *timeout)
+		err = walker.Compact(cctx, cli, target)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to compact at revision %d: %w", target, err)
+		}
+
+		after, err := checkHealthy(ctx, e, cli, *useEndpoints, "after compacting")
+		if after == nil {
+			return err
+		}
+		for _, r := range sizesAfter(before, after) {
+			record.Freed = max(record.Freed, r.InUseBefore-r.InUseAfter)
+			if werr := out.Write(r); werr != nil {
+				return werr
+			}
+		}
+		if werr := out.Write(record); err == nil {
+			err = werr
+		}
+		return err
+	}
+	return c
+}
@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/CedricElie/etcd-walker/walker"
+)
+
+// copyRecord is the summary of a cp run.
+type copyRecord struct{ *walker.CopyReport }
+
+func (r copyRecord) Columns() []string { return []string{"REVISION", "COPIED", "SKIPPED", "FAILED"} }
+func (r copyRecord) Row() []string {
+	return []string{strconv.FormatInt(r.Revision, 10), strconv.Itoa(r.Copied), strconv.Itoa(r.Skipped), strconv.Itoa(r.Failed)}
+}
+func (r copyRecord) Text(color bool) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Copied %d, skipped %d, failed %d keys (source revision %d)", r.Copied, r.Skipped, r.Failed, r.Revision)
+	for _, e := range r.Errors {
+		fmt.Fprintf(&sb, "\n  error: %s", e)
+	}
+	return sb.String()
+}
+
+func cpCommand() *command {
+	c := newCommand("cp", "SRC_PREFIX [DST_PREFIX]", "Copy every key under a prefix to another prefix or cluster", 1, 2)
+	toContext := c.flags.String("to-context", "", "Context from config to copy into (defaults to the source cluster)")
+	noOverwrite := c.flags.Bool("no-overwrite", false, "Skip keys that already exist at the destination")
+	chunk := c.flags.Int("chunk", walker.DefaultTxnOps, "Puts per transaction")
+
+	c.run = func(ctx context.Context, e *env, args []string) error {
+		src, dst := args[0], args[0]
+		if len(args) > 1 {
+			dst = args[1]
+		}
+		if *toContext == "" && dst == src {
+			return usagef("cp needs a different destination, give DST_PREFIX or --to-context")
+		}
+
+		srcCli, err := e.client()
+		if err != nil {
+			return err
+		}
+		dstCli := srcCli
+		if *toContext != "" {
+			if dstCli, err = e.clientFor(*toContext); err != nil {
+				return err
+			}
+		}
+		if err := e.writable(ctx, dstCli); err != nil {
+			return err
+		}
+		out, err := e.output()
+		if err != nil {
+			return err
+		}
+
+		if *toContext != "" {
+			log.Printf("Copying '%s' to '%s' in %s...", src, dst, *toContext)
+		} else {
+			log.Printf("Copying '%s' to '%s'...", src, dst)
+		}
+		report, err := walker.Copy(ctx, srcCli, dstCli, src, dst, walker.CopyOptions{ChunkSize: *chunk, NoOverwrite: *noOverwrite})
+		if werr := out.Write(copyRecord{report}); err == nil {
+			err = werr
+		}
+		if err != nil {
+			return fmt.Errorf("copy aborted: %w", err)
+		}
+		if report.Failed > 0 {
+			return fmt.Errorf("%d keys failed to copy", report.Failed)
+		}
+		return nil
+	}
+	return c
+}
@@ -0,0 +1,170 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/CedricElie/etcd-walker/walker"
+)
+
+// defragRecord totals a defrag run.
+type defragRecord struct {
+	Members   int           `json:"members"`
+	Reclaimed int64         `json:"reclaimed"`
+	Took      time.Duration `json:"took_ns"`
+}
+
+func (r defragRecord) Columns() []string { return []string{"MEMBERS", "RECLAIMED", "TOOK"} }
+func (r defragRecord) Row() []string {
+	return []string{strconv.Itoa(r.Members), humanBytes(r.Reclaimed), r.Took.Round(time.Millisecond).String()}
+}
+func (r defragRecord) Text(color bool) string {
+	return fmt.Sprintf("Defragmented %d members in %s, reclaimed %s", r.Members, r.Took.Round(time.Millisecond), humanBytes(r.Reclaimed))
+}
+
+// defragOrder returns the members to defragment with the leader last, so
+// that the cluster only has to elect a new one if it becomes unresponsive
+// after every follower went through fine.
+func defragOrder(status *walker.ClusterStatus) []walker.EndpointStatus {
+	var order []walker.EndpointStatus
+	var leader []walker.EndpointStatus
+	for _, m := range status.Members {
+		if m.IsLeader {
+			leader = append(leader, m)
+		} else {
+			order = append(order, m)
+		}
+	}
+	return append(order, leader...)
+}
+
+// healthRetries are the waits between health checks after a defragmentation.
+// A defragmented leader can lose its leadership and the election briefly
+// shows as a problem, so the cluster gets a few seconds to settle before it
+// is reported unhealthy.
+var healthRetries = []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}
+
+// checkSettled is checkHealthy, tried again after each of healthRetries while
+// the cluster is unhealthy or cannot be reached.
+func checkSettled(ctx context.Context, e *env, cli *clientv3.Client, useEndpoints bool, when string) (*walker.ClusterStatus, error) {
+	status, err := checkHealthy(ctx, e, cli, useEndpoints, when)
+	for _, wait := range healthRetries {
+		if err == nil {
+			break
+		}
+		log.Printf("%v; checking again in %s", err, wait)
+		select {
+		case <-ctx.Done():
+			return status, err
+		case <-time.After(wait):
+		}
+		status, err = checkHealthy(ctx, e, cli, useEndpoints, when)
+	}
+	return status, err
+}
+
+func defragCommand() *command {
+	c := newCommand("defrag", "", "Defragment every member to give the space freed by compact back to the filesystem", 0, 0)
+	oneAtATime := c.flags.Bool("one-at-a-time", true, "Defragment members one after the other, the leader last, checking health in between; false runs them all at once")
+	useEndpoints := c.flags.Bool("use-endpoints", false, "Defragment the configured endpoints instead of each member's advertised client URL")
+	timeout := c.flags.Duration("timeout", 10*time.Minute, "Timeout for defragmenting one member")
+
+	c.run = func(ctx context.Context, e *env, args []string) error {
+		cli, err := e.client()
+		if err != nil {
+			return err
+		}
+		out, err := e.output()
+		if err != nil {
+			return err
+		}
+
+		before, err := checkHealthy(ctx, e, cli, *useEndpoints, "before defragmenting")
+		if err != nil {
+			return err
+		}
+		start := time.Now()
+		total := defragRecord{}
+		write := func(r sizeRecord) error {
+			total.Members++
+			total.Reclaimed += r.SizeBefore - r.SizeAfter
+			return out.Write(r)
+		}
+
+		if !*oneAtATime {
+			errs := make([]error, len(before.Members))
+			var wg sync.WaitGroup
+			for i, m := range before.Members {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					dctx, cancel := context.WithTimeout(ctx, *timeout)
+					defer cancel()
+					errs[i] = walker.Defragment(dctx, cli, m.Endpoint)
+				}()
+			}
+			log.Printf("Defragmenting %d members at once...", len(before.Members))
+			wg.Wait()
+			for i, err := range errs {
+				if err != nil {
+					return fmt.Errorf("failed to defragment %s: %w", before.Members[i].Endpoint, err)
+				}
+			}
+			after, err := checkSettled(ctx, e, cli, *useEndpoints, "after defragmenting")
+			if after == nil {
+				return err
+			}
+			for _, r := range sizesAfter(before, after) {
+				r.Took = time.Since(start)
+				if werr := write(r); werr != nil {
+					return werr
+				}
+			}
+			total.Took = time.Since(start)
+			if werr := out.Write(total); err == nil {
+				err = werr
+			}
+			return err
+		}
+
+		// Each member must be back and the cluster healthy before the next one
+		// stops serving, so at most one member is ever down
+		for _, m := range defragOrder(before) {
+			log.Printf("Defragmenting %s (%x)...", m.Endpoint, m.MemberID)
+			memberStart := time.Now()
+			dctx, cancel := context.WithTimeout(ctx, *timeout)
+			err := walker.Defragment(dctx, cli, m.Endpoint)
+			cancel()
+			if err != nil {
+				return fmt.Errorf("failed to defragment %s: %w", m.Endpoint, err)
+			}
+			took := time.Since(memberStart)
+
+			after, err := checkSettled(ctx, e, cli, *useEndpoints, "after defragmenting "+m.Endpoint)
+			if after == nil {
+				return err
+			}
+			for _, r := range sizesAfter(before, after) {
+				if r.Endpoint != m.Endpoint {
+					continue
+				}
+				r.Took = took
+				if werr := write(r); werr != nil {
+					return werr
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+		total.Took = time.Since(start)
+		return out.Write(total)
+	}
+	return c
+}
@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+
+	"github.com/CedricElie/etcd-walker/walker"
+)
+
+// diffRecord is a key that differs between the two sides.
+type diffRecord struct {
+	Key            string `json:"key"`
+	Change         string `json:"change"`
+	OldModRevision int64  `json:"old_mod_revision,omitempty"`
+	NewModRevision int64  `json:"new_mod_revision,omitempty"`
+	Diff           string `json:"diff,omitempty"`
+}
+
+func (r diffRecord) Columns() []string {
+	return []string{"CHANGE", "KEY", "OLD_MOD_REV", "NEW_MOD_REV"}
+}
+func (r diffRecord) Row() []string {
+	return []string{r.Change, r.Key, strconv.FormatInt(r.OldModRevision, 10), strconv.FormatInt(r.NewModRevision, 10)}
+}
+func (r diffRecord) Text(color bool) string {
+	marks := map[string]string{walker.Added: "A", walker.Removed: "D", walker.Modified: "M"}
+	line := marks[r.Change] + " " + r.Key
+	if r.Diff == "" {
+		return line
+	}
+	return line + "\n" + indent(colorDiff(r.Diff, color))
+}
+
+// diffSummaryRecord totals a diff run.
+type diffSummaryRecord struct {
+	walker.CompareSummary
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func (r diffSummaryRecord) Columns() []string {
+	return []string{"FROM", "TO", "ADDED", "REMOVED", "MODIFIED", "UNCHANGED"}
+}
+func (r diffSummaryRecord) Row() []string {
+	return []string{r.From, r.To, strconv.Itoa(r.Added), strconv.Itoa(r.Removed), strconv.Itoa(r.Modified), strconv.Itoa(r.Unchanged)}
+}
+func (r diffSummaryRecord) Text(color bool) string {
+	return fmt.Sprintf("%s -> %s: %d added, %d removed, %d modified, %d unchanged",
+		r.From, r.To, r.Added, r.Removed, r.Modified, r.Unchanged)
+}
+
+func diffCommand() *command {
+	c := newCommand("diff", "[PREFIX]", "List keys added, removed and modified under a prefix between two revisions or two clusters", 0, 1)
+	fromRev := c.flags.Int64("from-rev", 0, "Older revision to compare, or the left cluster's revision")
+	toRev := c.flags.Int64("to-rev", 0, "Newer revision to compare, or the right cluster's revision; the current one when 0")
+	left := c.flags.String("left", "", "Context to compare from, instead of comparing revisions (default cluster when only --right is given)")
+	right := c.flags.String("right", "", "Context to compare to (default cluster when only --left is given)")
+	summary := c.flags.Bool("summary", false, "Only list changed keys, without value diffs")
+
+	c.run = func(ctx context.Context, e *env, args []string) error {
+		clusters := *left != "" || *right != ""
+		if !clusters {
+			if *fromRev <= 0 {
+				return usagef("diff needs --from-rev, or --left/--right to compare clusters")
+			}
+			if *toRev > 0 && *toRev < *fromRev {
+				return usagef("--to-rev %d is before --from-rev %d", *toRev, *fromRev)
+			}
+		}
+		prefix := ""
+		if len(args) > 0 {
+			prefix = args[0]
+		}
+
+		// Without --left/--right both sides are the default cluster
+		leftCli, err := e.clientFor(*left)
+		if err != nil {
+			return err
+		}
+		rightCli, err := e.clientFor(*right)
+		if err != nil {
+			return err
+		}
+		out, err := e.output()
+		if err != nil {
+			return err
+		}
+
+		older := walker.NewCursor(leftCli, prefix, walker.ScanOptions{Revision: *fromRev})
+		newer := walker.NewCursor(rightCli, prefix, walker.ScanOptions{Revision: *toRev})
+		sum, err := walker.Compare(ctx, older, newer, func(ch walker.Change) error {
+			return out.Write(newDiffRecord(ch, *summary))
+		})
+		if errors.Is(err, rpctypes.ErrCompacted) {
+			return fmt.Errorf("a requested revision has been compacted, pick a later one: %w", err)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to compare '%s': %w", prefix, err)
+		}
+
+		return out.Write(diffSummaryRecord{
+			CompareSummary: sum,
+			From:           side(*left, older.Revision(), clusters),
+			To:             side(*right, newer.Revision(), clusters),
+		})
+	}
+	return c
+}
+
+// side names one side of a diff for the summary.
+func side(context string, rev int64, clusters bool) string {
+	if !clusters {
+		return fmt.Sprintf("rev %d", rev)
+	}
+	if context == "" {
+		context = "default"
+	}
+	return fmt.Sprintf("%s@%d", context, rev)
+}
+
+func newDiffRecord(ch walker.Change, summary bool) diffRecord {
+	rec := diffRecord{Key: ch.Key, Change: ch.Kind}
+	if ch.Old != nil {
+		rec.OldModRevision = ch.Old.ModRevision
+	}
+	if ch.New != nil {
+		rec.NewModRevision = ch.New.ModRevision
+	}
+	if !summary && ch.Kind == walker.Modified {
+		rec.Diff = walker.DiffValues(ch.Old.Value, ch.New.Value)
+	}
+	return rec
+}
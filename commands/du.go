@@ -0,0 +1,159 @@
+package commands
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+
+	"github.com/CedricElie/etcd-walker/output"
+	"github.com/CedricElie/etcd-walker/walker"
+)
+
+// duRecord is the usage of one prefix.
+type duRecord struct {
+	Path       string  `json:"path"`
+	Depth      int     `json:"depth"`
+	Keys       int     `json:"keys"`
+	KeyBytes   int64   `json:"key_bytes"`
+	ValueBytes int64   `json:"value_bytes"`
+	TotalBytes int64   `json:"total_bytes"`
+	Percent    float64 `json:"percent"` // Of the total under the scanned prefix
+}
+
+func (r duRecord) Columns() []string { return []string{"SIZE", "PERCENT", "KEYS", "PATH"} }
+func (r duRecord) Row() []string {
+	return []string{humanBytes(r.TotalBytes), fmt.Sprintf("%.1f%%", r.Percent), strconv.Itoa(r.Keys), r.path()}
+}
+func (r duRecord) Text(color bool) string {
+	return fmt.Sprintf("%10s %6.1f%% %9d  %s", humanBytes(r.TotalBytes), r.Percent, r.Keys, r.path())
+}
+
+func (r duRecord) path() string {
+	if r.Path == "" {
+		return "(all keys)"
+	}
+	return r.Path
+}
+
+// duDBRecord compares the scanned data with the backend database size.
+type duDBRecord struct {
+	TotalBytes  int64   `json:"total_bytes"`
+	DBSize      int64   `json:"db_size"`
+	DBSizeInUse int64   `json:"db_size_in_use"`
+	Percent     float64 `json:"percent"` // Scanned bytes as a share of DBSizeInUse
+}
+
+func (r duDBRecord) Columns() []string {
+	return []string{"SCANNED", "DB_SIZE", "DB_IN_USE", "PERCENT_OF_IN_USE"}
+}
+func (r duDBRecord) Row() []string {
+	return []string{humanBytes(r.TotalBytes), humanBytes(r.DBSize), humanBytes(r.DBSizeInUse), fmt.Sprintf("%.1f%%", r.Percent)}
+}
+func (r duDBRecord) Text(color bool) string {
+	return fmt.Sprintf("Scanned %s is %.1f%% of the %s in use in a %s database (the rest is old revisions and overhead)",
+		humanBytes(r.TotalBytes), r.Percent, humanBytes(r.DBSizeInUse), humanBytes(r.DBSize))
+}
+
+func percent(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(part) / float64(total)
+}
+
+// duSorts orders siblings for --sort.
+var duSorts = map[string]func(a, b *walker.Node) int{
+	"name": func(a, b *walker.Node) int { return cmp.Compare(a.Name, b.Name) },
+	"size": func(a, b *walker.Node) int { return cmp.Compare(b.Bytes+b.KeyBytes, a.Bytes+a.KeyBytes) },
+	"keys": func(a, b *walker.Node) int { return cmp.Compare(b.Keys, a.Keys) },
+}
+
+func duCommand() *command {
+	c := newCommand("du", "[PREFIX]", "Report key and value bytes per prefix level", 0, 1)
+	c.offline = true
+	depth := c.flags.Int("depth", 1, "Levels to report below PREFIX, 0 for all")
+	sortBy := c.flags.String("sort", "size", "Order of prefixes at each level: size, keys or name")
+	compareDB := c.flags.Bool("db", false, "Compare the total with the backend database size from Maintenance Status")
+
+	c.run = func(ctx context.Context, e *env, args []string) error {
+		order, ok := duSorts[*sortBy]
+		if !ok {
+			return usagef("unknown sort %q, want size, keys or name", *sortBy)
+		}
+		if *compareDB && e.g.snapshot != "" {
+			return usagef("--db needs a cluster, not --snapshot")
+		}
+		prefix := ""
+		if len(args) > 0 {
+			prefix = args[0]
+		}
+		cli, err := e.client()
+		if err != nil {
+			return err
+		}
+		out, err := e.output()
+		if err != nil {
+			return err
+		}
+
+		tree := walker.NewTree(prefix, *depth)
+		_, err = walker.Scan(ctx, cli, prefix, walker.ScanOptions{}, func(kv *mvccpb.KeyValue) error {
+			tree.Add(string(kv.Key), len(kv.Value))
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to scan '%s': %w", prefix, err)
+		}
+		if err := writeDu(out.Write, tree.Root, order); err != nil {
+			return err
+		}
+
+		if !*compareDB {
+			return nil
+		}
+		sctx, cancel := e.timeout(ctx)
+		size, inUse, err := walker.DBSize(sctx, cli)
+		cancel()
+		if err != nil {
+			return err
+		}
+		total := tree.Root.Bytes + tree.Root.KeyBytes
+		return out.Write(duDBRecord{TotalBytes: total, DBSize: size, DBSizeInUse: inUse, Percent: percent(total, inUse)})
+	}
+	return c
+}
+
+// writeDu emits root and its descendants depth first, ordering siblings.
+func writeDu(write func(output.Record) error, root *walker.Node, order func(a, b *walker.Node) int) error {
+	total := root.Bytes + root.KeyBytes
+	var walk func(n *walker.Node, depth int) error
+	walk = func(n *walker.Node, depth int) error {
+		size := n.Bytes + n.KeyBytes
+		err := write(duRecord{
+			Path:       n.Path,
+			Depth:      depth,
+			Keys:       n.Keys,
+			KeyBytes:   n.KeyBytes,
+			ValueBytes: n.Bytes,
+			TotalBytes: size,
+			Percent:    percent(size, total),
+		})
+		if err != nil {
+			return err
+		}
+
+		children := n.Sorted()
+		slices.SortStableFunc(children, order)
+		for _, child := range children {
+			if err := walk(child, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(root, 0)
+}
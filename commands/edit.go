@@ -0,0 +1,240 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/CedricElie/etcd-walker/walker"
+)
+
+func editCommand() *command {
+	c := newCommand("edit", "KEY", "Edit a value in $EDITOR and write it back if nobody changed it meanwhile", 1, 1)
+	yes := c.flags.Bool("yes", false, "Write the edited value without asking for confirmation")
+
+	c.run = func(ctx context.Context, e *env, args []string) error {
+		key := args[0]
+		cli, err := e.client()
+		if err != nil {
+			return err
+		}
+		if err := e.writable(ctx, cli); err != nil {
+			return err
+		}
+
+		getCtx, cancel := e.timeout(ctx)
+		resp, err := cli.Get(getCtx, key)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to get '%s': %w", key, err)
+		}
+		if len(resp.Kvs) == 0 {
+			return fmt.Errorf("%w: '%s'", walker.ErrNotFound, key)
+		}
+		base := resp.Kvs[0]
+		if !walker.IsText(base.Value) {
+			return fmt.Errorf("'%s' holds binary data, which cannot be edited as text", key)
+		}
+
+		isJSON := json.Valid(base.Value)
+		ext := ".txt"
+		if isJSON {
+			ext = ".json"
+		}
+		f, err := os.CreateTemp("", "etcd-walker-*"+ext)
+		if err != nil {
+			return fmt.Errorf("failed to create temp file: %w", err)
+		}
+		path := f.Name()
+		text := editText(base.Value)
+		_, err = f.Write(text)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(path)
+			return fmt.Errorf("failed to write temp file: %w", err)
+		}
+		// The file is kept when the edit is not written, so no work is lost
+		keep := false
+		yours := "" // An edit that lost a conflict, kept to copy from
+		defer func() {
+			if keep {
+				log.Printf("Your edit is kept in %s", path)
+			} else {
+				os.Remove(path)
+				if yours != "" {
+					os.Remove(yours)
+				}
+			}
+		}()
+
+		in := bufio.NewReader(os.Stdin)
+		color := isTerminal(os.Stderr)
+		for {
+			if err := runEditor(path); err != nil {
+				keep = true
+				return err
+			}
+			edited, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read temp file: %w", err)
+			}
+			value := storedValue(base.Value, edited)
+			// Comparing the text too catches a reformat of multi-line JSON
+			if bytes.Equal(edited, text) || bytes.Equal(value, base.Value) {
+				log.Printf("No changes to '%s'", key)
+				return nil
+			}
+
+			if isJSON && !json.Valid(value) {
+				var syntax *json.SyntaxError
+				msg := "invalid JSON"
+				if err := json.Unmarshal(value, new(any)); errors.As(err, &syntax) {
+					msg = fmt.Sprintf("invalid JSON at offset %d: %v", syntax.Offset, syntax)
+				}
+				if ask(in, fmt.Sprintf("The value was JSON but the edit is %s. Edit again?", msg), true) {
+					continue
+				}
+				keep = true
+				return fmt.Errorf("not writing '%s': %s", key, msg)
+			}
+
+			fmt.Fprint(os.Stderr, colorDiff(walker.DiffValues(base.Value, value), color))
+			if !*yes && !ask(in, fmt.Sprintf("Write '%s'?", key), true) {
+				keep = true
+				return fmt.Errorf("not writing '%s'", key)
+			}
+
+			putCtx, cancel := e.timeout(ctx)
+			// A put without a lease detaches the key from its lease
+			res, err := walker.Put(putCtx, cli, key, value, walker.PutOptions{IfModRevision: base.ModRevision, Lease: base.Lease})
+			cancel()
+			var conflict *walker.ConflictError
+			if !errors.As(err, &conflict) {
+				if err != nil {
+					keep = true
+					return fmt.Errorf("failed to put '%s': %w", key, err)
+				}
+				// The output is opened late so nothing is printed under the editor
+				out, err := e.output()
+				if err != nil {
+					return err
+				}
+				return out.Write(newPutRecord(key, res))
+			}
+
+			// Someone else wrote the key: show what they changed and let the
+			// edit be redone on top of their version
+			if conflict.Current == nil {
+				keep = true
+				return fmt.Errorf("'%s' was deleted while it was being edited", key)
+			}
+			log.Printf("'%s' was changed while it was being edited (mod revision %d, now %d):", key, base.ModRevision, conflict.Current.ModRevision)
+			fmt.Fprint(os.Stderr, colorDiff(walker.DiffValues(base.Value, conflict.Current.Value), color))
+			if !ask(in, "Edit again on top of the current value?", true) {
+				keep = true
+				return fmt.Errorf("failed to put '%s': %w", key, err)
+			}
+
+			// The editor reopens on the current value, with the edit that
+			// lost saved next to it
+			yours = strings.TrimSuffix(path, ext) + ".yours" + ext
+			base, text = conflict.Current, editText(conflict.Current.Value)
+			err = os.WriteFile(yours, edited, 0o600)
+			if err == nil {
+				err = os.WriteFile(path, text, 0o600)
+			}
+			if err != nil {
+				keep = true
+				return fmt.Errorf("failed to write temp file: %w", err)
+			}
+			log.Printf("Your previous edit is in %s", yours)
+		}
+	}
+	return c
+}
+
+// editText is the text put in the editor: JSON is pretty-printed, anything
+// else is left as it is.
+func editText(v []byte) []byte {
+	if !json.Valid(v) {
+		return v
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, bytes.TrimSpace(v), "", "  "); err != nil {
+		return v
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// storedValue turns the edited text back into a value in the style of the
+// original: single-line JSON is compacted again, and the trailing newline
+// editors add is dropped when the original had none.
+func storedValue(orig, edited []byte) []byte {
+	if json.Valid(orig) && !bytes.Contains(bytes.TrimSpace(orig), []byte("\n")) {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, edited); err == nil {
+			return buf.Bytes()
+		}
+	}
+	if !bytes.HasSuffix(orig, []byte("\n")) {
+		edited = bytes.TrimSuffix(edited, []byte("\n"))
+	}
+	return edited
+}
+
+// runEditor opens path in $VISUAL or $EDITOR, falling back to vi. The
+// variable may hold arguments, as in "code --wait".
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	argv := append(strings.Fields(editor), path)
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor '%s' failed: %w", editor, err)
+	}
+	return nil
+}
+
+// ask prints a yes/no question on stderr and reads the answer from in.
+// An empty answer picks def, and end of input counts as no.
+func ask(in *bufio.Reader, question string, def bool) bool {
+	hint := " [y/N] "
+	if def {
+		hint = " [Y/n] "
+	}
+	fmt.Fprint(os.Stderr, question+hint)
+	line, err := in.ReadString('\n')
+	if err != nil && line == "" {
+		fmt.Fprintln(os.Stderr)
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "":
+		return def
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// isTerminal reports whether f is a terminal, for coloring diagnostics.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/grpc"
+)
+
+// fakeKV is a single-key store answering the ranges and guarded puts that
+// edit makes. Like etcd, a put without a lease detaches the key from its
+// lease.
+type fakeKV struct {
+	etcdserverpb.KVClient
+	kv  *mvccpb.KeyValue
+	rev int64
+}
+
+func (f *fakeKV) Range(ctx context.Context, r *etcdserverpb.RangeRequest, _ ...grpc.CallOption) (*etcdserverpb.RangeResponse, error) {
+	resp := &etcdserverpb.RangeResponse{Header: &etcdserverpb.ResponseHeader{Revision: f.rev}}
+	if f.kv != nil && string(r.Key) == string(f.kv.Key) {
+		resp.Kvs, resp.Count = []*mvccpb.KeyValue{f.kv}, 1
+	}
+	return resp, nil
+}
+
+func (f *fakeKV) Txn(ctx context.Context, r *etcdserverpb.TxnRequest, _ ...grpc.CallOption) (*etcdserverpb.TxnResponse, error) {
+	for _, c := range r.Compare {
+		if c.Target != etcdserverpb.Compare_MOD || c.Result != etcdserverpb.Compare_EQUAL || f.kv.ModRevision != c.GetModRevision() {
+			resp, _ := f.Range(ctx, r.Failure[0].GetRequestRange())
+			return &etcdserverpb.TxnResponse{
+				Header:    resp.Header,
+				Responses: []*etcdserverpb.ResponseOp{{Response: &etcdserverpb.ResponseOp_ResponseRange{ResponseRange: resp}}},
+			}, nil
+		}
+	}
+	put := r.Success[0].GetRequestPut()
+	prev := f.kv
+	f.rev++
+	f.kv = &mvccpb.KeyValue{Key: put.Key, Value: put.Value, Lease: put.Lease,
+		CreateRevision: prev.CreateRevision, ModRevision: f.rev, Version: prev.Version + 1}
+	return &etcdserverpb.TxnResponse{
+		Header:    &etcdserverpb.ResponseHeader{Revision: f.rev},
+		Succeeded: true,
+		Responses: []*etcdserverpb.ResponseOp{{Response: &etcdserverpb.ResponseOp_ResponsePut{
+			ResponsePut: &etcdserverpb.PutResponse{PrevKv: prev},
+		}}},
+	}, nil
+}
+
+// noAlarms is a cluster without alarms, so edit finds it writable.
+type noAlarms struct{ etcdserverpb.MaintenanceClient }
+
+func (noAlarms) Alarm(context.Context, *etcdserverpb.AlarmRequest, ...grpc.CallOption) (*etcdserverpb.AlarmResponse, error) {
+	return &etcdserverpb.AlarmResponse{Header: &etcdserverpb.ResponseHeader{}}, nil
+}
+
+func TestEditKeepsLease(t *testing.T) {
+	kv := &fakeKV{rev: 5, kv: &mvccpb.KeyValue{Key: []byte("/leased"), Value: []byte("old\n"), Lease: 0x1a2b,
+		CreateRevision: 3, ModRevision: 5, Version: 2}}
+	cli := clientv3.NewCtxClient(context.Background())
+	cli.KV = clientv3.NewKVFromKVClient(kv, cli)
+	cli.Maintenance = clientv3.NewMaintenanceFromMaintenanceClient(noAlarms{}, cli)
+
+	t.Setenv("VISUAL", "sed -i s/old/new/")
+	g := &globals{commandTimeout: 5 * time.Second, format: "text", out: filepath.Join(t.TempDir(), "out")}
+	e := &env{g: g, clients: map[string]*clientv3.Client{"": cli}}
+	c := editCommand()
+	if err := c.flags.Parse([]string{"--yes"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.run(context.Background(), e, []string{"/leased"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := string(kv.kv.Value); got != "new\n" {
+		t.Errorf("value = %q, want %q", got, "new\n")
+	}
+	if kv.kv.Lease != 0x1a2b {
+		t.Errorf("lease = %x, want 1a2b", kv.kv.Lease)
+	}
+	out, err := os.ReadFile(g.out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Updated '/leased' at revision 6 (was mod revision 5) with lease 1a2b\n"; string(out) != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+}
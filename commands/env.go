@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/CedricElie/etcd-walker/config"
+	"github.com/CedricElie/etcd-walker/output"
+	"github.com/CedricElie/etcd-walker/snapshot"
+	"github.com/CedricElie/etcd-walker/walker"
+)
+
+// env is what a running command shares: connections, opened on first use
+// and keyed by context name, and the output writer.
+type env struct {
+	g        *globals
+	clients  map[string]*clientv3.Client
+	snapshot *snapshot.DB // Behind the selected cluster's client with --snapshot
+	out      *output.Writer
+}
+
+// endpoints resolves a context name from the config file. The empty name is
+// the cluster selected by --endpoints or --context, or else ETCD_HOST.
+func (e *env) endpoints(name string) ([]string, error) {
+	if name == "" {
+		if e.g.endpoints != "" {
+			return config.SplitEndpoints(e.g.endpoints), nil
+		}
+		name = e.g.context
+	}
+	return config.GetConfig().Endpoints(name)
+}
+
+// client returns the client for the selected cluster.
+func (e *env) client() (*clientv3.Client, error) {
+	return e.clientFor("")
+}
+
+// clientFor returns the client for a named context, connecting if needed.
+func (e *env) clientFor(name string) (*clientv3.Client, error) {
+	if cli, ok := e.clients[name]; ok {
+		return cli, nil
+	}
+
+	var cli *clientv3.Client
+	if name == "" && e.g.snapshot != "" {
+		db, err := snapshot.Open(e.g.snapshot)
+		if err != nil {
+			return nil, err
+		}
+		e.snapshot = db
+		cli = db.Client(context.Background())
+	} else {
+		endpoints, err := e.endpoints(name)
+		if err != nil {
+			return nil, err
+		}
+		cli, err = walker.Connect(endpoints, e.g.dialTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to %v: %w", endpoints, err)
+		}
+	}
+
+	if e.clients == nil {
+		e.clients = make(map[string]*clientv3.Client)
+	}
+	e.clients[name] = cli
+	return cli, nil
+}
+
+// writable fails early when cli's cluster has a NOSPACE alarm raised, so
+// that write commands explain it rather than failing on the first put.
+func (e *env) writable(ctx context.Context, cli *clientv3.Client) error {
+	ctx, cancel := e.timeout(ctx)
+	defer cancel()
+	return walker.CheckNoSpace(ctx, cli)
+}
+
+// output returns the writer for --out in --format.
+func (e *env) output() (*output.Writer, error) {
+	if e.out != nil {
+		return e.out, nil
+	}
+	format, err := output.ParseFormat(e.g.format)
+	if err != nil {
+		return nil, err
+	}
+	if e.out, err = output.Open(e.g.out, format); err != nil {
+		return nil, err
+	}
+	return e.out, nil
+}
+
+// timeout bounds a single request by --command-timeout.
+func (e *env) timeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, e.g.commandTimeout)
+}
+
+// close closes every connection and flushes the output, returning any
+// error from the latter since it means results were lost.
+func (e *env) close() error {
+	for _, cli := range e.clients {
+		cli.Close()
+	}
+	if e.snapshot != nil {
+		e.snapshot.Close()
+	}
+	if e.out != nil {
+		return e.out.Close()
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+)
+
+func getCommand() *command {
+	c := newCommand("get", "KEY", "Print a single key", 1, 1)
+	c.offline = true
+	c.run = func(ctx context.Context, e *env, args []string) error {
+		cli, err := e.client()
+		if err != nil {
+			return err
+		}
+		out, err := e.output()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := e.timeout(ctx)
+		resp, err := cli.Get(ctx, args[0])
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to get '%s': %w", args[0], err)
+		}
+		if len(resp.Kvs) == 0 {
+			return fmt.Errorf("key '%s' not found", args[0])
+		}
+		return out.Write(newKVRecord(resp.Kvs[0]))
+	}
+	return c
+}
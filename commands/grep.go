@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+
+	"github.com/CedricElie/etcd-walker/output"
+	"github.com/CedricElie/etcd-walker/walker"
+)
+
+// grepRecord is a grep match.
+type grepRecord struct{ walker.GrepMatch }
+
+func (r grepRecord) Columns() []string { return []string{"KEY", "MOD_REV", "SNIPPET"} }
+func (r grepRecord) Row() []string {
+	return []string{r.Key, strconv.FormatInt(r.ModRevision, 10), r.snippet(false)}
+}
+func (r grepRecord) Text(color bool) string {
+	if !r.InValue {
+		return fmt.Sprintf("%s (rev %d)", r.Key, r.ModRevision)
+	}
+	return fmt.Sprintf("%s (rev %d): %s", r.Key, r.ModRevision, r.snippet(color))
+}
+
+func (r grepRecord) snippet(color bool) string {
+	if !r.InValue {
+		return ""
+	}
+	return "..." + r.Before + output.Highlight(r.Match, color) + r.After + "..."
+}
+
+func grepCommand() *command {
+	c := newCommand("grep", "PATTERN [PREFIX]", "Search keys and values under a prefix with a regular expression", 1, 2)
+	c.offline = true
+	match := c.flags.String("match", "both", "What to match against: keys, values or both")
+
+	c.run = func(ctx context.Context, e *env, args []string) error {
+		re, err := regexp.Compile(args[0])
+		if err != nil {
+			return usagef("invalid pattern: %v", err)
+		}
+		target, err := walker.ParseGrepTarget(*match)
+		if err != nil {
+			return usagef("%v", err)
+		}
+		prefix := ""
+		if len(args) > 1 {
+			prefix = args[1]
+		}
+
+		cli, err := e.client()
+		if err != nil {
+			return err
+		}
+		out, err := e.output()
+		if err != nil {
+			return err
+		}
+
+		matches := 0
+		_, err = walker.Grep(ctx, cli, prefix, re, target, func(m walker.GrepMatch) error {
+			matches++
+			return out.Write(grepRecord{m})
+		})
+		if err != nil {
+			return fmt.Errorf("failed to search '%s': %w", prefix, err)
+		}
+		log.Printf("%d matching keys", matches)
+		return nil
+	}
+	return c
+}
@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+
+	"github.com/CedricElie/etcd-walker/walker"
+)
+
+// historyRecord is one retained version of a key.
+type historyRecord struct {
+	ModRevision int64  `json:"mod_revision"`
+	Version     int64  `json:"version"`
+	Value       string `json:"value"`
+	Diff        string `json:"diff,omitempty"` // From the previous version
+	showValue   bool
+}
+
+func (r historyRecord) Columns() []string {
+	return []string{"MOD_REV", "VERSION", "SIZE", "CHANGED_LINES"}
+}
+func (r historyRecord) Row() []string {
+	changed := 0
+	for _, l := range strings.Split(r.Diff, "\n") {
+		if strings.HasPrefix(l, "+") || strings.HasPrefix(l, "-") {
+			changed++
+		}
+	}
+	return []string{strconv.FormatInt(r.ModRevision, 10), strconv.FormatInt(r.Version, 10), strconv.Itoa(len(r.Value)), strconv.Itoa(changed)}
+}
+func (r historyRecord) Text(color bool) string {
+	body := colorDiff(r.Diff, color)
+	if r.showValue {
+		body = r.Value + "\n"
+	}
+	return fmt.Sprintf("revision %d (version %d)\n%s", r.ModRevision, r.Version, indent(body))
+}
+
+// historyEndRecord says where the history stops.
+type historyEndRecord struct{ walker.HistoryEnd }
+
+func (r historyEndRecord) Columns() []string { return []string{"STOPPED", "AT_REVISION"} }
+func (r historyEndRecord) Row() []string {
+	return []string{r.Reason, strconv.FormatInt(r.Revision, 10)}
+}
+func (r historyEndRecord) Text(color bool) string {
+	switch r.Reason {
+	case "created":
+		return fmt.Sprintf("Created at revision %d; if it was deleted and re-created, its earlier versions are not shown", r.Revision)
+	case "compacted":
+		return fmt.Sprintf("History stops at revision %d: earlier revisions have been compacted", r.Revision)
+	}
+	return fmt.Sprintf("Stopped at revision %d after the requested number of versions", r.Revision)
+}
+
+// colorDiff colours removed lines red and added lines green.
+func colorDiff(diff string, color bool) string {
+	if !color || diff == "" {
+		return diff
+	}
+	lines := strings.SplitAfter(diff, "\n")
+	for i, l := range lines {
+		switch {
+		case strings.HasPrefix(l, "-"):
+			lines[i] = "\033[31m" + strings.TrimSuffix(l, "\n") + "\033[0m\n"
+		case strings.HasPrefix(l, "+"):
+			lines[i] = "\033[32m" + strings.TrimSuffix(l, "\n") + "\033[0m\n"
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// indent prefixes every line of s with two spaces, dropping the final newline.
+func indent(s string) string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return ""
+	}
+	return "  " + strings.ReplaceAll(s, "\n", "\n  ")
+}
+
+func historyCommand() *command {
+	c := newCommand("history", "KEY", "Show the retained versions of a key with diffs between them", 1, 1)
+	rev := c.flags.Int64("rev", 0, "Start from the version current at this revision, for deleted keys")
+	limit := c.flags.Int("limit", 0, "Stop after this many versions, 0 for all")
+	values := c.flags.Bool("values", false, "Show each version's full value instead of a diff")
+
+	c.run = func(ctx context.Context, e *env, args []string) error {
+		cli, err := e.client()
+		if err != nil {
+			return err
+		}
+		out, err := e.output()
+		if err != nil {
+			return err
+		}
+
+		// Each version is written once the one before it is known
+		var pending *mvccpb.KeyValue
+		emit := func(kv, prev *mvccpb.KeyValue) error {
+			rec := historyRecord{ModRevision: kv.ModRevision, Version: kv.Version, Value: string(kv.Value), showValue: *values}
+			if prev != nil {
+				rec.Diff = walker.DiffValues(prev.Value, kv.Value)
+			}
+			return out.Write(rec)
+		}
+
+		end, err := walker.History(ctx, cli, args[0], *rev, *limit, func(kv *mvccpb.KeyValue) error {
+			if pending != nil {
+				if err := emit(pending, kv); err != nil {
+					return err
+				}
+			}
+			pending = kv
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read history of '%s': %w", args[0], err)
+		}
+
+		// The creating version is diffed against nothing; a version at the
+		// compaction horizon or limit has an unknown predecessor
+		var before *mvccpb.KeyValue
+		if end.Reason == "created" {
+			before = &mvccpb.KeyValue{}
+		}
+		if err := emit(pending, before); err != nil {
+			return err
+		}
+		return out.Write(historyEndRecord{end})
+	}
+	return c
+}
@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CedricElie/etcd-walker/walker"
+)
+
+// leaseRecord is a lease listed by the lease command.
+type leaseRecord struct{ walker.LeaseInfo }
+
+func (r leaseRecord) Columns() []string { return []string{"ID", "GRANTED_TTL", "TTL", "KEYS"} }
+func (r leaseRecord) Row() []string {
+	return []string{strconv.FormatInt(int64(r.ID), 16), strconv.FormatInt(r.GrantedTTL, 10), strconv.FormatInt(r.TTL, 10), strconv.Itoa(r.KeyCount)}
+}
+func (r leaseRecord) Text(color bool) string {
+	s := fmt.Sprintf("%016x  %s left of %s  %s", r.ID, seconds(r.TTL), seconds(r.GrantedTTL), keyCount(r.KeyCount))
+	for _, k := range r.Keys {
+		s += "\n  " + k
+	}
+	return s
+}
+
+// seconds formats a TTL in seconds as a duration.
+func seconds(s int64) string {
+	return (time.Duration(s) * time.Second).String()
+}
+
+func leaseCommand() *command {
+	c := newCommand("lease", "ls | show ID | orphans", "List leases with their TTLs, show the keys of one, or find leases without keys", 1, 2)
+	keys := c.flags.Bool("keys", false, "List the keys attached to the leases shown")
+
+	c.run = func(ctx context.Context, e *env, args []string) error {
+		sub := args[0]
+		switch {
+		case sub == "show" && len(args) != 2:
+			return usagef("lease show needs the lease ID")
+		case (sub == "ls" || sub == "orphans") && len(args) != 1:
+			return usagef("lease %s takes no arguments", sub)
+		case sub != "ls" && sub != "show" && sub != "orphans":
+			return usagef("unknown lease command %q, want ls, show or orphans", sub)
+		}
+		cli, err := e.client()
+		if err != nil {
+			return err
+		}
+		out, err := e.output()
+		if err != nil {
+			return err
+		}
+
+		switch sub {
+		case "show":
+			// IDs are printed in hex, as etcdctl does
+			id, err := strconv.ParseInt(strings.TrimPrefix(args[1], "0x"), 16, 64)
+			if err != nil {
+				return usagef("bad lease ID %q, want the hex ID from lease ls", args[1])
+			}
+			ctx, cancel := e.timeout(ctx)
+			info, err := walker.Lease(ctx, cli, id, *keys)
+			cancel()
+			if err != nil {
+				return fmt.Errorf("failed to look up lease %x: %w", id, err)
+			}
+			return out.Write(leaseRecord{info})
+
+		case "orphans":
+			total, orphans := 0, 0
+			err := walker.Leases(ctx, cli, false, func(info walker.LeaseInfo) error {
+				total++
+				if info.KeyCount > 0 {
+					return nil
+				}
+				orphans++
+				return out.Write(leaseRecord{info})
+			})
+			if err != nil {
+				return fmt.Errorf("failed to list leases: %w", err)
+			}
+			log.Printf("%d of %d leases have no keys attached", orphans, total)
+			return nil
+
+		default:
+			err := walker.Leases(ctx, cli, *keys, func(info walker.LeaseInfo) error {
+				return out.Write(leaseRecord{info})
+			})
+			if err != nil {
+				return fmt.Errorf("failed to list leases: %w", err)
+			}
+			return nil
+		}
+	}
+	return c
+}
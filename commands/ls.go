@@ -0,0 +1,234 @@
+package commands
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/CedricElie/etcd-walker/output"
+	"github.com/CedricElie/etcd-walker/walker"
+)
+
+// kvRecord is a key listed by ls or get. Values that are not UTF-8 would be
+// mangled in JSON, so they are base64 encoded and ValueEncoding says so.
+type kvRecord struct {
+	Key            string `json:"key"`
+	Value          string `json:"value"`
+	ValueEncoding  string `json:"value_encoding,omitempty"` // "base64", or empty for the value as is
+	CreateRevision int64  `json:"create_revision"`
+	ModRevision    int64  `json:"mod_revision"`
+	Version        int64  `json:"version"`
+}
+
+func newKVRecord(kv *mvccpb.KeyValue) kvRecord {
+	r := kvRecord{
+		Key:            string(kv.Key),
+		Value:          string(kv.Value),
+		CreateRevision: kv.CreateRevision,
+		ModRevision:    kv.ModRevision,
+		Version:        kv.Version,
+	}
+	if !utf8.Valid(kv.Value) {
+		r.Value, r.ValueEncoding = base64.StdEncoding.EncodeToString(kv.Value), "base64"
+	}
+	return r
+}
+
+func (r kvRecord) Columns() []string { return []string{"KEY", "VALUE"} }
+func (r kvRecord) Row() []string     { return []string{r.Key, r.Value} }
+func (r kvRecord) Text(color bool) string {
+	return fmt.Sprintf("Key '%s', Value = '%s'", r.Key, r.Value)
+}
+
+// keyRecord is a key listed by ls --keys-only.
+type keyRecord struct {
+	Key            string `json:"key"`
+	CreateRevision int64  `json:"create_revision"`
+	ModRevision    int64  `json:"mod_revision"`
+	Version        int64  `json:"version"`
+}
+
+func (r keyRecord) Columns() []string      { return []string{"KEY"} }
+func (r keyRecord) Row() []string          { return []string{r.Key} }
+func (r keyRecord) Text(color bool) string { return r.Key }
+
+// countRecord is the result of ls --count-only.
+type countRecord struct {
+	Prefix   string `json:"prefix"`
+	Count    int64  `json:"count"`
+	Revision int64  `json:"revision"`
+}
+
+func (r countRecord) Columns() []string { return []string{"PREFIX", "COUNT", "REVISION"} }
+func (r countRecord) Row() []string {
+	return []string{r.Prefix, strconv.FormatInt(r.Count, 10), strconv.FormatInt(r.Revision, 10)}
+}
+func (r countRecord) Text(color bool) string {
+	return fmt.Sprintf("%d keys under '%s' at revision %d", r.Count, r.Prefix, r.Revision)
+}
+
+// longRecord is a key listed by ls -l.
+type longRecord struct {
+	Key            string         `json:"key"`
+	CreateRevision int64          `json:"create_revision"`
+	ModRevision    int64          `json:"mod_revision"`
+	Version        int64          `json:"version"`
+	Lease          walker.LeaseID `json:"lease,omitempty"`
+	Size           int            `json:"size"`
+	Encoding       string         `json:"encoding"`
+}
+
+func newLongRecord(kv *mvccpb.KeyValue) longRecord {
+	return longRecord{
+		Key:            string(kv.Key),
+		CreateRevision: kv.CreateRevision,
+		ModRevision:    kv.ModRevision,
+		Version:        kv.Version,
+		Lease:          walker.LeaseID(kv.Lease),
+		Size:           len(kv.Value),
+		Encoding:       walker.Encoding(kv.Value),
+	}
+}
+
+func (r longRecord) Columns() []string {
+	return []string{"KEY", "CREATE_REV", "MOD_REV", "VERSION", "LEASE", "SIZE", "ENCODING"}
+}
+func (r longRecord) Row() []string {
+	return []string{r.Key, strconv.FormatInt(r.CreateRevision, 10), strconv.FormatInt(r.ModRevision, 10),
+		strconv.FormatInt(r.Version, 10), r.lease(), strconv.Itoa(r.Size), r.Encoding}
+}
+func (r longRecord) Text(color bool) string {
+	return fmt.Sprintf("%10d %10d %6d %16s %10s  %-8s  %s",
+		r.CreateRevision, r.ModRevision, r.Version, r.lease(), humanBytes(int64(r.Size)), r.Encoding, r.Key)
+}
+
+func (r longRecord) lease() string {
+	if r.Lease == 0 {
+		return "-"
+	}
+	return strconv.FormatInt(int64(r.Lease), 16)
+}
+
+// lsSorts orders ls -l output for --sort. Everything but key puts the
+// newest or largest first.
+var lsSorts = map[string]func(a, b longRecord) int{
+	"key":        func(a, b longRecord) int { return cmp.Compare(a.Key, b.Key) },
+	"mod-rev":    func(a, b longRecord) int { return cmp.Compare(b.ModRevision, a.ModRevision) },
+	"create-rev": func(a, b longRecord) int { return cmp.Compare(b.CreateRevision, a.CreateRevision) },
+	"size":       func(a, b longRecord) int { return cmp.Compare(b.Size, a.Size) },
+}
+
+// errLimit stops a scan once --limit keys were listed.
+var errLimit = errors.New("limit reached")
+
+func lsCommand() *command {
+	c := newCommand("ls", "PREFIX", "List keys and values under a prefix, page by page", 1, 1)
+	c.offline = true
+	limit := c.flags.Int64("limit", 0, "Stop after this many keys, 0 for all")
+	keysOnly := c.flags.Bool("keys-only", false, "List keys without fetching values")
+	countOnly := c.flags.Bool("count-only", false, "Only print the number of keys")
+	pageSize := c.flags.Int64("page-size", walker.DefaultPageSize, "Keys fetched per request")
+	long := c.flags.Bool("l", false, "Long listing: revisions, version, lease, value size and encoding instead of values")
+	sortBy := c.flags.String("sort", "key", "Order of the long listing: key, mod-rev, create-rev or size")
+
+	c.run = func(ctx context.Context, e *env, args []string) error {
+		if *limit < 0 || *pageSize <= 0 {
+			return usagef("--limit cannot be negative and --page-size must be positive")
+		}
+		order, ok := lsSorts[*sortBy]
+		if !ok {
+			return usagef("unknown sort %q, want key, mod-rev, create-rev or size", *sortBy)
+		}
+		if *sortBy != "key" && !*long {
+			return usagef("--sort needs -l")
+		}
+		if *long && *keysOnly {
+			return usagef("-l needs values for their size, drop --keys-only")
+		}
+		cli, err := e.client()
+		if err != nil {
+			return err
+		}
+		out, err := e.output()
+		if err != nil {
+			return err
+		}
+
+		if *countOnly {
+			ctx, cancel := e.timeout(ctx)
+			resp, err := cli.Get(ctx, args[0], clientv3.WithPrefix(), clientv3.WithCountOnly())
+			cancel()
+			if err != nil {
+				return fmt.Errorf("failed to count '%s': %w", args[0], err)
+			}
+			return out.Write(countRecord{Prefix: args[0], Count: resp.Count, Revision: resp.Header.Revision})
+		}
+
+		// Sorting other than by key needs every key before the first is
+		// written, and then --limit picks the top ones
+		if *sortBy != "key" {
+			var recs []longRecord
+			_, err := walker.Scan(ctx, cli, args[0], walker.ScanOptions{PageSize: *pageSize}, func(kv *mvccpb.KeyValue) error {
+				recs = append(recs, newLongRecord(kv))
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("failed to list '%s': %w", args[0], err)
+			}
+			slices.SortStableFunc(recs, order)
+			if *limit > 0 && int64(len(recs)) > *limit {
+				recs = recs[:*limit]
+			}
+			for _, rec := range recs {
+				if err := out.Write(rec); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		// Every page is read at the first page's revision, and keys are
+		// written as each page arrives
+		opts := walker.ScanOptions{PageSize: *pageSize, KeysOnly: *keysOnly}
+		if *limit > 0 {
+			opts.PageSize = min(opts.PageSize, *limit)
+		}
+		var n int64
+		rev, err := walker.Scan(ctx, cli, args[0], opts, func(kv *mvccpb.KeyValue) error {
+			var rec output.Record
+			switch {
+			case *long:
+				rec = newLongRecord(kv)
+			case *keysOnly:
+				rec = keyRecord{Key: string(kv.Key), CreateRevision: kv.CreateRevision, ModRevision: kv.ModRevision, Version: kv.Version}
+			default:
+				rec = newKVRecord(kv)
+			}
+			if err := out.Write(rec); err != nil {
+				return err
+			}
+			if n++; n == *limit {
+				return errLimit
+			}
+			return nil
+		})
+		if errors.Is(err, errLimit) {
+			log.Printf("Stopped after %d keys (--limit) at revision %d", n, rev)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list '%s': %w", args[0], err)
+		}
+		return nil
+	}
+	return c
+}
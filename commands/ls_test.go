@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+)
+
+func TestKVRecordValue(t *testing.T) {
+	tests := []struct {
+		value    string
+		encoding string
+	}{
+		{"", ""},
+		{`{"a":"é"}`, ""},
+		{"\x00\xff\xfek8s", "base64"},
+	}
+	for _, tt := range tests {
+		r := newKVRecord(&mvccpb.KeyValue{Key: []byte("/k"), Value: []byte(tt.value)})
+		b, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var back kvRecord
+		if err := json.Unmarshal(b, &back); err != nil {
+			t.Fatal(err)
+		}
+		got := back.Value
+		if back.ValueEncoding == "base64" {
+			raw, err := base64.StdEncoding.DecodeString(back.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = string(raw)
+		}
+		if got != tt.value || back.ValueEncoding != tt.encoding {
+			t.Errorf("value %q round-tripped through %s as %q with encoding %q, want encoding %q", tt.value, b, got, back.ValueEncoding, tt.encoding)
+		}
+	}
+}
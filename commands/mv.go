@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/CedricElie/etcd-walker/walker"
+)
+
+// moveRecord is a key moved by mv.
+type moveRecord struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func (r moveRecord) Columns() []string { return []string{"FROM", "TO"} }
+func (r moveRecord) Row() []string     { return []string{r.From, r.To} }
+func (r moveRecord) Text(color bool) string {
+	return fmt.Sprintf("%s -> %s", r.From, r.To)
+}
+
+// moveSummaryRecord totals an mv run.
+type moveSummaryRecord struct{ *walker.MoveReport }
+
+func (r moveSummaryRecord) Columns() []string { return []string{"REVISION", "MOVED", "FAILED"} }
+func (r moveSummaryRecord) Row() []string {
+	return []string{strconv.FormatInt(r.Revision, 10), strconv.Itoa(r.Moved), r.Failed}
+}
+func (r moveSummaryRecord) Text(color bool) string {
+	if r.Failed != "" {
+		return fmt.Sprintf("Moved %d keys read at revision %d, then stopped: %s", r.Moved, r.Revision, r.Failed)
+	}
+	return fmt.Sprintf("Moved %d keys read at revision %d", r.Moved, r.Revision)
+}
+
+// mvArgs applies what a trailing "/" on SRC means: the keys under it, moved
+// under DST as a directory too, so that mv /old/ /new gives /new/a rather
+// than /newa.
+func mvArgs(src, dst string, prefix bool) (string, string, bool) {
+	if strings.HasSuffix(src, "/") {
+		prefix = true
+		if !strings.HasSuffix(dst, "/") {
+			dst += "/"
+		}
+	}
+	return src, dst, prefix
+}
+
+func mvCommand() *command {
+	c := newCommand("mv", "SRC DST", "Rename a key, or every key under a prefix, atomically per transaction", 2, 2)
+	prefix := c.flags.Bool("prefix", false, "Move every key under SRC to the same suffix under DST; implied when SRC ends in /, which DST then gets too")
+	overwrite := c.flags.Bool("overwrite", false, "Replace destination keys that already exist")
+
+	c.run = func(ctx context.Context, e *env, args []string) error {
+		src, dst, prefix := mvArgs(args[0], args[1], *prefix)
+		cli, err := e.client()
+		if err != nil {
+			return err
+		}
+		if err := e.writable(ctx, cli); err != nil {
+			return err
+		}
+		out, err := e.output()
+		if err != nil {
+			return err
+		}
+
+		report, err := walker.Move(ctx, cli, src, dst, walker.MoveOptions{Prefix: prefix, Overwrite: *overwrite}, func(from, to string) error {
+			return out.Write(moveRecord{From: from, To: to})
+		})
+		if report == nil {
+			return usagef("%v", err)
+		}
+		if errors.Is(err, walker.ErrNotFound) && !prefix {
+			err = fmt.Errorf("%w (use --prefix to move everything under it)", err)
+		}
+		if werr := out.Write(moveSummaryRecord{report}); err == nil {
+			err = werr
+		}
+		return err
+	}
+	return c
+}
@@ -0,0 +1,25 @@
+package commands
+
+import "testing"
+
+func TestMvArgs(t *testing.T) {
+	tests := []struct {
+		src, dst   string
+		prefix     bool
+		wantDst    string
+		wantPrefix bool
+	}{
+		{"/old", "/new", false, "/new", false},
+		{"/old", "/new", true, "/new", true},
+		{"/old/", "/new/", false, "/new/", true},
+		{"/old/", "/new", false, "/new/", true},
+		{"/old/", "/new", true, "/new/", true},
+	}
+	for _, tt := range tests {
+		src, dst, prefix := mvArgs(tt.src, tt.dst, tt.prefix)
+		if src != tt.src || dst != tt.wantDst || prefix != tt.wantPrefix {
+			t.Errorf("mvArgs(%q, %q, %v) = %q, %q, %v, want %q, %q, %v",
+				tt.src, tt.dst, tt.prefix, src, dst, prefix, tt.src, tt.wantDst, tt.wantPrefix)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CedricElie/etcd-walker/walker"
+)
+
+// putRecord is a value written by put.
+type putRecord struct {
+	Key             string         `json:"key"`
+	Revision        int64          `json:"revision"`
+	Lease           walker.LeaseID `json:"lease,omitempty"`
+	PrevModRevision int64          `json:"prev_mod_revision,omitempty"` // Zero when the key was created
+}
+
+func (r putRecord) Columns() []string { return []string{"KEY", "REVISION", "PREV_MOD_REV", "LEASE"} }
+func (r putRecord) Row() []string {
+	return []string{r.Key, strconv.FormatInt(r.Revision, 10), strconv.FormatInt(r.PrevModRevision, 10), strconv.FormatInt(int64(r.Lease), 16)}
+}
+func (r putRecord) Text(color bool) string {
+	s := fmt.Sprintf("Created '%s' at revision %d", r.Key, r.Revision)
+	if r.PrevModRevision > 0 {
+		s = fmt.Sprintf("Updated '%s' at revision %d (was mod revision %d)", r.Key, r.Revision, r.PrevModRevision)
+	}
+	if r.Lease != 0 {
+		s += fmt.Sprintf(" with lease %x", r.Lease)
+	}
+	return s
+}
+
+func newPutRecord(key string, res *walker.PutResult) putRecord {
+	rec := putRecord{Key: key, Revision: res.Revision, Lease: res.Lease}
+	if res.Prev != nil {
+		rec.PrevModRevision = res.Prev.ModRevision
+	}
+	return rec
+}
+
+// optionalString is a string flag that records whether it was given, for
+// flags where the empty string is a meaningful value.
+type optionalString struct{ value *string }
+
+func (o *optionalString) String() string {
+	if o.value == nil {
+		return ""
+	}
+	return *o.value
+}
+
+func (o *optionalString) Set(s string) error {
+	o.value = &s
+	return nil
+}
+
+func putCommand() *command {
+	c := newCommand("put", "KEY [VALUE|-|@FILE|@@VALUE]", "Write a key, optionally only if it is unchanged, absent or has a given value", 1, 2)
+	ifModRev := c.flags.Int64("if-mod-rev", 0, "Only write if the key's mod revision is this")
+	ifAbsent := c.flags.Bool("if-absent", false, "Only write if the key does not exist")
+	var ifValue optionalString
+	c.flags.Var(&ifValue, "if-value-equals", "Only write if the key's current value is this")
+	leaseTTL := c.flags.Duration("lease-ttl", 0, "Attach the key to a new lease that expires after this long, in whole seconds")
+
+	c.run = func(ctx context.Context, e *env, args []string) error {
+		if *ifAbsent && (*ifModRev > 0 || ifValue.value != nil) {
+			return usagef("--if-absent cannot be combined with --if-mod-rev or --if-value-equals")
+		}
+		if *leaseTTL < 0 || *leaseTTL%time.Second != 0 {
+			return usagef("--lease-ttl must be a whole number of seconds, such as 30s or 5m")
+		}
+		value, err := readValue(args[1:])
+		if err != nil {
+			return err
+		}
+		cli, err := e.client()
+		if err != nil {
+			return err
+		}
+		if err := e.writable(ctx, cli); err != nil {
+			return err
+		}
+		out, err := e.output()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := e.timeout(ctx)
+		defer cancel()
+		res, err := walker.Put(ctx, cli, args[0], value, walker.PutOptions{
+			IfModRevision: *ifModRev,
+			IfAbsent:      *ifAbsent,
+			IfValue:       ifValue.value,
+			LeaseTTL:      int64(leaseTTL.Seconds()),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to put '%s': %w", args[0], err)
+		}
+		return out.Write(newPutRecord(args[0], res))
+	}
+	return c
+}
+
+// readValue returns the value argument of put: the literal, stdin for "-" or
+// no argument, the contents of the file named after "@", or the literal after
+// the first "@" of "@@". Without an argument a terminal on stdin is refused
+// rather than waited on.
+func readValue(args []string) ([]byte, error) {
+	switch {
+	case len(args) == 0 && isTerminal(os.Stdin):
+		return nil, usagef("no value given, pass VALUE, - to type it on stdin, or @FILE")
+	case len(args) == 0 || args[0] == "-":
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read value from stdin: %w", err)
+		}
+		return b, nil
+	case strings.HasPrefix(args[0], "@@"):
+		return []byte(args[0][1:]), nil
+	case strings.HasPrefix(args[0], "@"):
+		b, err := os.ReadFile(args[0][1:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read value: %w", err)
+		}
+		return b, nil
+	default:
+		return []byte(args[0]), nil
+	}
+}
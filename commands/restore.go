@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/CedricElie/etcd-walker/walker"
+)
+
+// restoreRecord totals a restore run.
+type restoreRecord struct {
+	*walker.RestoreReport
+	File string `json:"file"`
+}
+
+func (r restoreRecord) Columns() []string { return []string{"FILE", "RESTORED", "SKIPPED"} }
+func (r restoreRecord) Row() []string {
+	return []string{r.File, strconv.Itoa(r.Restored), strconv.Itoa(r.Skipped)}
+}
+func (r restoreRecord) Text(color bool) string {
+	s := fmt.Sprintf("Restored %s from %s", keyCount(r.Restored), r.File)
+	if r.Skipped > 0 {
+		s += fmt.Sprintf(", skipped %s that already exist (use --overwrite to replace them)", keyCount(r.Skipped))
+	}
+	return s
+}
+
+func restoreCommand() *command {
+	c := newCommand("restore", "FILE", "Put back the keys of a backup written by rm", 1, 1)
+	overwrite := c.flags.Bool("overwrite", false, "Replace keys that exist again since the backup")
+
+	c.run = func(ctx context.Context, e *env, args []string) error {
+		cli, err := e.client()
+		if err != nil {
+			return err
+		}
+		if err := e.writable(ctx, cli); err != nil {
+			return err
+		}
+		out, err := e.output()
+		if err != nil {
+			return err
+		}
+
+		report, err := walker.Restore(ctx, cli, args[0], *overwrite)
+		if report == nil {
+			return err
+		}
+		if werr := out.Write(restoreRecord{RestoreReport: report, File: args[0]}); err == nil {
+			err = werr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to restore '%s': %w", args[0], err)
+		}
+		return nil
+	}
+	return c
+}
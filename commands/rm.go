@@ -0,0 +1,161 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+
+	"github.com/CedricElie/etcd-walker/walker"
+)
+
+// rmPlanRecord is the preview rm prints before deleting anything.
+type rmPlanRecord struct {
+	*walker.DeletePlan
+	Key    string `json:"key"`
+	DryRun bool   `json:"dry_run"`
+}
+
+func (r rmPlanRecord) Columns() []string {
+	return []string{"KEY", "REVISION", "KEYS", "BYTES", "DRY_RUN"}
+}
+func (r rmPlanRecord) Row() []string {
+	return []string{r.Key, strconv.FormatInt(r.Revision, 10), strconv.Itoa(r.Keys), strconv.FormatInt(r.Bytes, 10), strconv.FormatBool(r.DryRun)}
+}
+func (r rmPlanRecord) Text(color bool) string {
+	verb := "Deleting"
+	if r.DryRun {
+		verb = "Would delete"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s (%s) matching '%s' at revision %d:", verb, keyCount(r.Keys), humanBytes(r.Bytes), r.Key, r.Revision)
+	for _, k := range r.Sample {
+		fmt.Fprintf(&b, "\n  %s", k)
+	}
+	if more := r.Keys - len(r.Sample); more > 0 {
+		fmt.Fprintf(&b, "\n  ... and %d more", more)
+	}
+	if r.DryRun {
+		b.WriteString("\nRun again with --yes to back them up and delete them")
+	}
+	return b.String()
+}
+
+// deletedRecord is a key the server confirmed deleting.
+type deletedRecord struct {
+	Key         string `json:"key"`
+	ModRevision int64  `json:"mod_revision"`
+	Size        int    `json:"size"`
+}
+
+func (r deletedRecord) Columns() []string { return []string{"KEY", "MOD_REV", "SIZE"} }
+func (r deletedRecord) Row() []string {
+	return []string{r.Key, strconv.FormatInt(r.ModRevision, 10), strconv.Itoa(r.Size)}
+}
+func (r deletedRecord) Text(color bool) string {
+	return "deleted " + r.Key
+}
+
+// rmSummaryRecord totals an rm run.
+type rmSummaryRecord struct {
+	Deleted int    `json:"deleted"`
+	Backup  string `json:"backup"`
+	Failed  string `json:"failed,omitempty"`
+}
+
+func (r rmSummaryRecord) Columns() []string { return []string{"DELETED", "BACKUP", "FAILED"} }
+func (r rmSummaryRecord) Row() []string {
+	return []string{strconv.Itoa(r.Deleted), r.Backup, r.Failed}
+}
+func (r rmSummaryRecord) Text(color bool) string {
+	s := fmt.Sprintf("Deleted %s, backup in %s (etcd-walker restore %s puts them back)", keyCount(r.Deleted), r.Backup, r.Backup)
+	if r.Failed != "" {
+		s += "\nStopped early: " + r.Failed
+	}
+	return s
+}
+
+func rmCommand() *command {
+	c := newCommand("rm", "KEY", "Delete a key, or every key under a prefix, after previewing and backing them up", 1, 1)
+	prefix := c.flags.Bool("prefix", false, "Delete every key under KEY")
+	yes := c.flags.Bool("yes", false, "Actually delete; without it rm only shows what would be deleted")
+	backupPath := c.flags.String("backup", "", "Backup file to write before deleting (default rm-backup-TIMESTAMP.jsonl)")
+	sample := c.flags.Int("sample", 10, "Number of keys to show in the preview")
+
+	c.run = func(ctx context.Context, e *env, args []string) error {
+		key := args[0]
+		if *prefix && key == "" {
+			return usagef("refusing to delete the whole keyspace")
+		}
+		cli, err := e.client()
+		if err != nil {
+			return err
+		}
+		out, err := e.output()
+		if err != nil {
+			return err
+		}
+
+		if !*yes {
+			plan, err := walker.PlanDelete(ctx, cli, key, *prefix, *sample, nil)
+			if err != nil {
+				return fmt.Errorf("failed to read '%s': %w", key, err)
+			}
+			if plan.Keys == 0 {
+				return notFound(key, *prefix)
+			}
+			return out.Write(rmPlanRecord{DeletePlan: plan, Key: key, DryRun: true})
+		}
+
+		// The backup holds exactly the keys read at the plan's revision, and
+		// Delete only removes those, so everything deleted can be restored
+		if *backupPath == "" {
+			*backupPath = "rm-backup-" + time.Now().Format("20060102-150405") + ".jsonl"
+		}
+		backup, err := walker.CreateBackup(*backupPath)
+		if err != nil {
+			return err
+		}
+		plan, err := walker.PlanDelete(ctx, cli, key, *prefix, *sample, backup.Add)
+		if cerr := backup.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil || plan.Keys == 0 {
+			os.Remove(*backupPath)
+			if err != nil {
+				return fmt.Errorf("failed to back up '%s': %w", key, err)
+			}
+			return notFound(key, *prefix)
+		}
+		log.Printf("Backed up %s to %s", keyCount(plan.Keys), *backupPath)
+		if err := out.Write(rmPlanRecord{DeletePlan: plan, Key: key}); err != nil {
+			return err
+		}
+
+		deleted, err := walker.Delete(ctx, cli, key, *prefix, plan.Revision, func(prev *mvccpb.KeyValue) error {
+			return out.Write(deletedRecord{Key: string(prev.Key), ModRevision: prev.ModRevision, Size: len(prev.Value)})
+		})
+		summary := rmSummaryRecord{Deleted: deleted, Backup: *backupPath}
+		if err != nil {
+			summary.Failed = err.Error()
+		}
+		if werr := out.Write(summary); err == nil {
+			err = werr
+		}
+		return err
+	}
+	return c
+}
+
+// notFound reports that rm matched nothing.
+func notFound(key string, prefix bool) error {
+	if prefix {
+		return fmt.Errorf("%w under '%s'", walker.ErrNotFound, key)
+	}
+	return fmt.Errorf("%w: '%s' (use --prefix to delete everything under it)", walker.ErrNotFound, key)
+}
@@ -0,0 +1,604 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"golang.org/x/term"
+
+	"github.com/CedricElie/etcd-walker/output"
+	"github.com/CedricElie/etcd-walker/walker"
+)
+
+// entryRecord is a name listed by the shell's ls.
+type entryRecord struct {
+	Name        string `json:"name"`
+	Dir         bool   `json:"dir"`
+	Keys        int    `json:"keys,omitempty"`
+	Bytes       int64  `json:"bytes,omitempty"`
+	ModRevision int64  `json:"mod_revision,omitempty"`
+	Version     int64  `json:"version,omitempty"`
+	long        bool
+}
+
+func (r entryRecord) Columns() []string {
+	if !r.long {
+		return []string{"NAME"}
+	}
+	return []string{"NAME", "DIR", "KEYS", "BYTES", "MOD_REV", "VERSION"}
+}
+func (r entryRecord) Row() []string {
+	if !r.long {
+		return []string{r.display()}
+	}
+	return []string{r.display(), strconv.FormatBool(r.Dir), strconv.Itoa(r.Keys), strconv.FormatInt(r.Bytes, 10),
+		strconv.FormatInt(r.ModRevision, 10), strconv.FormatInt(r.Version, 10)}
+}
+func (r entryRecord) Text(color bool) string {
+	switch {
+	case !r.long:
+		return r.display()
+	case r.Dir:
+		return fmt.Sprintf("d %10s %9s %12s  %s", humanBytes(r.Bytes), keyCount(r.Keys), "", r.display())
+	default:
+		return fmt.Sprintf("- %10s %9s %12s  %s", humanBytes(r.Bytes), fmt.Sprintf("v%d", r.Version), fmt.Sprintf("rev %d", r.ModRevision), r.Name)
+	}
+}
+
+func (r entryRecord) display() string {
+	if r.Dir && r.Name != "/" {
+		return r.Name + "/"
+	}
+	return r.Name
+}
+
+// shellPaths are the etcd-walker commands the shell runs, with the index of
+// their path argument, resolved against the current prefix. Directory
+// arguments default to the current prefix when left out.
+var shellPaths = map[string]struct {
+	arg int
+	dir bool
+}{
+	"get":     {0, false},
+	"history": {0, false},
+	"tree":    {0, true},
+	"du":      {0, true},
+	"grep":    {1, true},
+	"watch":   {0, true},
+}
+
+// shell is an interactive session keeping a current prefix.
+type shell struct {
+	e    *env
+	cli  *clientv3.Client
+	cwd  string    // Current prefix, ends with "/" unless it is the top, ""
+	w    io.Writer // Where results go
+	errw io.Writer // Where errors go
+	term bool
+}
+
+func shellCommand() *command {
+	c := newCommand("shell", "[PREFIX]", "Browse keys interactively with cd, ls, cat, tree, grep, history and tab completion", 0, 1)
+	c.run = func(ctx context.Context, e *env, args []string) error {
+		cli, err := e.client()
+		if err != nil {
+			return err
+		}
+		sh := &shell{e: e, cli: cli, cwd: "/", w: os.Stdout, errw: os.Stderr}
+		if len(args) > 0 {
+			sh.cwd = sh.dir(args[0])
+		}
+
+		// ^C interrupts the command being run, not the shell
+		ctx = context.WithoutCancel(ctx)
+		fd := int(os.Stdin.Fd())
+		if !term.IsTerminal(fd) {
+			return sh.script(ctx, os.Stdin)
+		}
+		return sh.interactive(ctx, fd)
+	}
+	return c
+}
+
+// interactive reads lines with editing, history and tab completion. Lines
+// are edited in raw mode and commands run in the normal mode, so that ^C
+// interrupts them.
+func (sh *shell) interactive(ctx context.Context, fd int) error {
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("failed to set up the terminal: %w", err)
+	}
+	defer term.Restore(fd, state)
+
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{clearOnInterrupt{os.Stdin}, os.Stdout}, "")
+	if width, height, err := term.GetSize(fd); err == nil {
+		t.SetSize(width, height)
+	}
+	t.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		return sh.complete(ctx, line, pos)
+	}
+	sh.w, sh.errw, sh.term = t, t, true
+	log.SetOutput(t)
+	defer log.SetOutput(os.Stderr)
+
+	fmt.Fprintln(t, "Type help for the commands, ^D to leave.")
+	for {
+		t.SetPrompt("etcd:" + sh.where() + "> ")
+		line, err := t.ReadLine()
+		if err == io.EOF {
+			fmt.Fprintln(t)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		term.Restore(fd, state)
+		quit := sh.exec(ctx, line) == errQuit
+		if _, err := term.MakeRaw(fd); err != nil {
+			return err
+		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+// script runs commands read from a pipe or file, one per line.
+func (sh *shell) script(ctx context.Context, r io.Reader) error {
+	failed := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		switch err := sh.exec(ctx, scanner.Text()); err {
+		case nil:
+		case errQuit:
+			return nil
+		default:
+			failed++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d commands failed", failed)
+	}
+	return nil
+}
+
+var errQuit = errors.New("quit")
+
+// exec runs one line, printing any error, and returns it.
+func (sh *shell) exec(ctx context.Context, line string) error {
+	words, err := splitWords(line)
+	if err == nil && len(words) == 0 {
+		return nil
+	}
+	if err == nil {
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+		defer stop()
+
+		switch words[0] {
+		case "exit", "quit":
+			return errQuit
+		case "help":
+			sh.help()
+		case "pwd":
+			fmt.Fprintln(sh.w, sh.where())
+		case "cd":
+			err = sh.cd(ctx, words[1:])
+		case "ls":
+			err = sh.ls(ctx, words[1:])
+		case "cat":
+			err = sh.cat(ctx, words[1:])
+		default:
+			err = sh.run(ctx, words)
+		}
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Fprintf(sh.errw, "Error: %v\n", err)
+	}
+	return err
+}
+
+func (sh *shell) help() {
+	fmt.Fprintln(sh.w, "Commands:")
+	fmt.Fprintf(sh.w, "  %-10s %s\n", "cd", "Change the current prefix; .. goes up, / to the root, and .. from / to the keys not starting with /")
+	fmt.Fprintf(sh.w, "  %-10s %s\n", "pwd", "Print the current prefix")
+	fmt.Fprintf(sh.w, "  %-10s %s\n", "ls", "List the names under a prefix; -l adds sizes, revisions and key counts")
+	fmt.Fprintf(sh.w, "  %-10s %s\n", "cat", "Print the value of keys")
+	for _, c := range commandList() {
+		if _, ok := shellPaths[c.name]; ok {
+			fmt.Fprintf(sh.w, "  %-10s %s\n", c.name, c.summary)
+		}
+	}
+	fmt.Fprintf(sh.w, "  %-10s %s\n", "exit", "Leave the shell")
+	fmt.Fprintln(sh.w, "Paths are relative to the current prefix. Tab completes commands and key names.")
+}
+
+// resolve turns a path typed in the shell into a key. Relative paths are
+// taken from the current prefix, and "." and ".." segments are applied. The
+// parent of "/" is the top of the keyspace, "", where the keys that do not
+// start with "/" are.
+func (sh *shell) resolve(p string) string {
+	if !strings.HasPrefix(p, "/") {
+		p = sh.cwd + p
+	}
+	rooted := strings.HasPrefix(p, "/")
+	var segments []string
+	for _, s := range strings.Split(p, "/") {
+		switch {
+		case s == "" || s == ".":
+		case s != "..":
+			segments = append(segments, s)
+		case len(segments) > 0:
+			segments = segments[:len(segments)-1]
+		default:
+			rooted = false
+		}
+	}
+	key := strings.Join(segments, "/")
+	if rooted {
+		key = "/" + key
+	}
+	if strings.HasSuffix(p, "/") && len(segments) > 0 {
+		key += "/"
+	}
+	return key
+}
+
+// dir resolves a path that names a prefix, which ends in "/" unless it is
+// the top.
+func (sh *shell) dir(p string) string {
+	d := sh.resolve(p)
+	if d != "" && !strings.HasSuffix(d, "/") {
+		d += "/"
+	}
+	return d
+}
+
+// where is the current prefix as shown in the prompt and by pwd.
+func (sh *shell) where() string {
+	if sh.cwd == "" {
+		return `""`
+	}
+	return sh.cwd
+}
+
+// entryOf is the entry key shows up as in d. At the top every key starting
+// with "/" is under "/", which SplitKey leaves out as an empty segment.
+func entryOf(d, key string) (walker.DirEntry, bool) {
+	if d == "" && strings.HasPrefix(key, "/") {
+		return walker.DirEntry{Name: "/", Dir: true}, true
+	}
+	return walker.SplitKey(d, key)
+}
+
+// exists tells whether there are keys under d.
+func (sh *shell) exists(ctx context.Context, d string) (bool, error) {
+	tctx, cancel := sh.e.timeout(ctx)
+	defer cancel()
+	resp, err := sh.cli.Get(tctx, d, clientv3.WithPrefix(), clientv3.WithKeysOnly(), clientv3.WithLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return len(resp.Kvs) > 0, nil
+}
+
+func (sh *shell) cd(ctx context.Context, args []string) error {
+	if len(args) > 1 {
+		return usagef("cd takes one prefix")
+	}
+	d := "/"
+	if len(args) == 1 {
+		d = sh.dir(args[0])
+	}
+	if d != "/" && d != "" {
+		ok, err := sh.exists(ctx, d)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("no keys under '%s'", d)
+		}
+	}
+	sh.cwd = d
+	return nil
+}
+
+func (sh *shell) ls(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("ls", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	long := fs.Bool("l", false, "")
+	args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return usagef("ls [-l] [PREFIX]: %v", err)
+	}
+	if len(args) > 1 {
+		return usagef("ls takes one prefix")
+	}
+	d := sh.cwd
+	if len(args) == 1 {
+		d = sh.dir(args[0])
+	}
+
+	return sh.withOutput(func(e *env) error {
+		if !*long {
+			entries, err := walker.ListDir(ctx, sh.cli, d, "", 0)
+			if err != nil {
+				return err
+			}
+			if d == "" {
+				root, err := sh.exists(ctx, "/")
+				if err != nil {
+					return err
+				}
+				if root {
+					entries = append([]walker.DirEntry{{Name: "/", Dir: true}}, entries...)
+				}
+			}
+			for _, entry := range entries {
+				if err := e.out.Write(entryRecord{Name: entry.Name, Dir: entry.Dir}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		// The long listing reads values to total up each directory
+		records := make(map[string]*entryRecord)
+		_, err := walker.Scan(ctx, sh.cli, d, walker.ScanOptions{}, func(kv *mvccpb.KeyValue) error {
+			entry, ok := entryOf(d, string(kv.Key))
+			if !ok {
+				return nil
+			}
+			r := records[entry.Name]
+			if r == nil {
+				r = &entryRecord{Name: entry.Name, long: true}
+				records[entry.Name] = r
+			}
+			r.Dir = r.Dir || entry.Dir
+			r.Keys++
+			r.Bytes += int64(len(kv.Value))
+			if !entry.Dir {
+				r.ModRevision, r.Version = kv.ModRevision, kv.Version
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		names := make([]string, 0, len(records))
+		for name := range records {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := e.out.Write(*records[name]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (sh *shell) cat(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return usagef("cat needs KEY")
+	}
+	for _, arg := range args {
+		key := sh.resolve(arg)
+		tctx, cancel := sh.e.timeout(ctx)
+		resp, err := sh.cli.Get(tctx, key)
+		cancel()
+		if err != nil {
+			return err
+		}
+		if len(resp.Kvs) == 0 {
+			return fmt.Errorf("%w: '%s'", walker.ErrNotFound, key)
+		}
+		v := resp.Kvs[0].Value
+		if !walker.IsText(v) {
+			fmt.Fprintf(sh.w, "(%s of binary data, use get --format json to see it)\n", humanBytes(int64(len(v))))
+			continue
+		}
+		fmt.Fprint(sh.w, string(v))
+		if len(v) > 0 && v[len(v)-1] != '\n' {
+			fmt.Fprintln(sh.w)
+		}
+	}
+	return nil
+}
+
+// run runs an etcd-walker command inside the shell, with its path argument
+// resolved. Each run gets fresh flags so options don't stick between lines.
+func (sh *shell) run(ctx context.Context, words []string) error {
+	spec, ok := shellPaths[words[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q, type help for the list", words[0])
+	}
+	c := findCommand(commandList(), words[0])
+	c.flags.SetOutput(io.Discard)
+	args, err := parseInterspersed(c.flags, words[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintf(sh.w, "Usage: %s [flags] %s\n\n%s\n", c.name, c.args, c.summary)
+		printDefaults(sh.w, c.flags)
+		return nil
+	}
+	if err != nil {
+		return usagef("%v", err)
+	}
+
+	switch {
+	case len(args) > spec.arg && spec.dir:
+		args[spec.arg] = sh.dir(args[spec.arg])
+	case len(args) > spec.arg:
+		args[spec.arg] = sh.resolve(args[spec.arg])
+	case len(args) == spec.arg && spec.dir:
+		args = append(args, sh.cwd)
+	}
+	if err := checkArgs(c, args); err != nil {
+		return err
+	}
+	return sh.withOutput(func(e *env) error { return c.run(ctx, e, args) })
+}
+
+// withOutput runs fn with an env that shares the shell's connections and
+// writes to the shell's output in --format.
+func (sh *shell) withOutput(fn func(e *env) error) error {
+	format, err := output.ParseFormat(sh.e.g.format)
+	if err != nil {
+		return err
+	}
+	out := output.New(sh.w, format)
+	out.SetColor(sh.term)
+	err = fn(&env{g: sh.e.g, clients: sh.e.clients, out: out})
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// complete is the tab completion callback: the first word completes to a
+// command, later ones to the names under the prefix typed so far, looked up
+// keys-only.
+func (sh *shell) complete(ctx context.Context, line string, pos int) (string, int, bool) {
+	head := line[:pos]
+	start := strings.LastIndexAny(head, " \t") + 1
+	word := head[start:]
+
+	var candidates []string
+	typed := word
+	if strings.TrimSpace(head[:start]) == "" {
+		names := []string{"cd", "pwd", "ls", "cat", "help", "exit"}
+		for name := range shellPaths {
+			names = append(names, name)
+		}
+		for _, name := range names {
+			if strings.HasPrefix(name, word) {
+				candidates = append(candidates, name+" ")
+			}
+		}
+	} else {
+		slash := strings.LastIndex(word, "/") + 1
+		d := sh.cwd
+		if slash > 0 {
+			d = sh.dir(word[:slash])
+		}
+		typed = word[slash:]
+
+		tctx, cancel := sh.e.timeout(ctx)
+		entries, err := walker.ListDir(tctx, sh.cli, d, typed, 200)
+		cancel()
+		if err != nil {
+			return line, pos, true
+		}
+		dirsOnly := strings.HasPrefix(strings.TrimSpace(head), "cd ")
+		for _, e := range entries {
+			switch {
+			case e.Dir:
+				candidates = append(candidates, e.Name+"/")
+			case !dirsOnly:
+				candidates = append(candidates, e.Name+" ")
+			}
+		}
+	}
+	sort.Strings(candidates)
+
+	var insert string
+	switch len(candidates) {
+	case 0:
+		return line, pos, true
+	case 1:
+		insert = candidates[0][len(typed):]
+	default:
+		common := candidates[0]
+		for _, c := range candidates[1:] {
+			for !strings.HasPrefix(c, common) {
+				_, size := utf8.DecodeLastRuneInString(common)
+				common = common[:len(common)-size]
+			}
+		}
+		if insert = common[len(typed):]; insert == "" {
+			// Nothing more in common: list the choices above the prompt
+			fmt.Fprintln(sh.w, strings.Join(candidates, " "))
+		}
+	}
+	return line[:pos] + insert + line[pos:], pos + len(insert), true
+}
+
+// splitWords splits a shell line on spaces, honouring single and double
+// quotes and backslash escapes, so that grep patterns can hold spaces.
+func splitWords(line string) ([]string, error) {
+	var words []string
+	var cur strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, usagef("unterminated quote or escape")
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+	return words, nil
+}
+
+// clearOnInterrupt turns ^C into ^U while a line is edited, so that it
+// clears the line as in other shells instead of ending the session.
+type clearOnInterrupt struct{ io.Reader }
+
+func (r clearOnInterrupt) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	for i := range p[:n] {
+		if p[i] == 3 {
+			p[i] = 21
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,148 @@
+package commands
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CedricElie/etcd-walker/snapshot"
+	"github.com/CedricElie/etcd-walker/snapshot/snapshottest"
+)
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", nil},
+		{"  ls  -l\t/a ", []string{"ls", "-l", "/a"}},
+		{`grep "a b" /x`, []string{"grep", "a b", "/x"}},
+		{`cat 'it''s'`, []string{"cat", "its"}},
+		{`cat 'a\b'`, []string{"cat", `a\b`}},
+		{`cat "a\"b"`, []string{"cat", `a"b`}},
+		{`cat a\ b`, []string{"cat", "a b"}},
+		{`cat ""`, []string{"cat", ""}},
+		{"cat été", []string{"cat", "été"}},
+	}
+	for _, tt := range tests {
+		got, err := splitWords(tt.line)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitWords(%q) = %q, %v, want %q", tt.line, got, err, tt.want)
+		}
+	}
+	for _, line := range []string{`cat "a`, `cat 'a`, `cat a\`} {
+		if got, err := splitWords(line); err == nil {
+			t.Errorf("splitWords(%q) = %q, want an error", line, got)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		cwd, path string
+		key, dir  string
+	}{
+		{"/", "a", "/a", "/a/"},
+		{"/a/", "b/", "/a/b/", "/a/b/"},
+		{"/a/", "/b", "/b", "/b/"},
+		{"/a/b/", "..", "/a", "/a/"},
+		{"/a/b/", "../c", "/a/c", "/a/c/"},
+		{"/a/", "./b/./c", "/a/b/c", "/a/b/c/"},
+		{"/a/", "b//c", "/a/b/c", "/a/b/c/"},
+		{"/", "/", "/", "/"},
+		{"/a/", "..", "/", "/"},
+		{"/", "..", "", ""},
+		{"/", "../plain", "plain", "plain/"},
+		{"/a/", "../../x/y", "x/y", "x/y/"},
+		{"", "..", "", ""},
+		{"", "plain", "plain", "plain/"},
+		{"", "/", "/", "/"},
+		{"x/", "..", "", ""},
+		{"x/", "y", "x/y", "x/y/"},
+	}
+	for _, tt := range tests {
+		sh := &shell{cwd: tt.cwd}
+		if got := sh.resolve(tt.path); got != tt.key {
+			t.Errorf("in %q, resolve(%q) = %q, want %q", tt.cwd, tt.path, got, tt.key)
+		}
+		if got := sh.dir(tt.path); got != tt.dir {
+			t.Errorf("in %q, dir(%q) = %q, want %q", tt.cwd, tt.path, got, tt.dir)
+		}
+	}
+}
+
+// testShell returns a shell over a snapshot holding keys, writing everything
+// to out.
+func testShell(t *testing.T, out *strings.Builder, keys ...string) *shell {
+	t.Helper()
+	b := snapshottest.New(t)
+	for _, key := range keys {
+		b.Put(key, "value of "+key)
+	}
+	db, err := snapshot.Open(b.Close())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	e := &env{g: &globals{commandTimeout: 5 * time.Second, format: "text"}}
+	return &shell{e: e, cli: db.Client(context.Background()), cwd: "/", w: out, errw: out}
+}
+
+func TestShellScript(t *testing.T) {
+	var out strings.Builder
+	sh := testShell(t, &out, "/a/b", "/a/c", "/z", "plain", "other/x")
+	script := "cat z\ncd ..\npwd\nls\ncat plain\ncd other\nls\ncd /a\npwd\nls\ncat ../z\ncd nowhere\n"
+	if err := sh.script(context.Background(), strings.NewReader(script)); err == nil {
+		t.Error("script with a failed cd succeeded")
+	}
+	want := `value of /z
+""
+/
+other/
+plain
+value of plain
+x
+/a/
+b
+c
+value of /z
+Error: no keys under '/a/nowhere/'
+`
+	if out.String() != want {
+		t.Errorf("output =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	var out strings.Builder
+	sh := testShell(t, &out, "/app/config", "/app/data/1", "/apple", "/x/été", "/x/èté", "plain")
+	tests := []struct {
+		cwd, line string
+		want      string
+		listed    string // Choices printed when there is nothing to insert
+	}{
+		{"/", "hist", "history ", ""},
+		{"/", "cat ap", "cat app", ""},
+		{"/", "cat app/", "cat app/", "config  data/\n"},
+		{"/", "cat app/c", "cat app/config ", ""},
+		{"/", "cd app/", "cd app/data/", ""},
+		{"/", "cat /app/d", "cat /app/data/", ""},
+		{"/", "cat ../p", "cat ../plain ", ""},
+		{"", "cat pl", "cat plain ", ""},
+		{"/x/", "cat ", "cat ", "èté  été \n"}, // Both start with the same byte
+		{"/", "cat nothing", "cat nothing", ""},
+	}
+	for _, tt := range tests {
+		out.Reset()
+		sh.cwd = tt.cwd
+		got, pos, ok := sh.complete(context.Background(), tt.line, len(tt.line))
+		if got != tt.want || pos != len(tt.want) || !ok {
+			t.Errorf("in %q, complete(%q) = %q, %d, %v, want %q, %d", tt.cwd, tt.line, got, pos, ok, tt.want, len(tt.want))
+		}
+		if out.String() != tt.listed {
+			t.Errorf("in %q, complete(%q) printed %q, want %q", tt.cwd, tt.line, out.String(), tt.listed)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/CedricElie/etcd-walker/snapshot"
+	"github.com/CedricElie/etcd-walker/walker"
+)
+
+// snapshotRecord is a snapshot saved and verified by snapshot save.
+type snapshotRecord struct {
+	snapshot.Meta
+	File string `json:"file"`
+}
+
+func (r snapshotRecord) Columns() []string {
+	return []string{"FILE", "ENDPOINT", "MEMBER", "REVISION", "SIZE", "SHA256"}
+}
+func (r snapshotRecord) Row() []string {
+	return []string{r.File, r.Endpoint, strconv.FormatUint(r.MemberID, 16), strconv.FormatInt(r.Revision, 10), humanBytes(r.Size), r.SHA256}
+}
+func (r snapshotRecord) Text(color bool) string {
+	return fmt.Sprintf("Saved %s from %s (member %x) at revision %d: %s, sha256 %s, verified; metadata in %s",
+		r.File, r.Endpoint, r.MemberID, r.Revision, humanBytes(r.Size), r.SHA256, snapshot.MetaPath(r.File))
+}
+
+func snapshotCommand() *command {
+	c := newCommand("snapshot", "save FILE", "Save a verified snapshot of a member's database, with a metadata file next to it", 2, 2)
+	from := c.flags.String("from", "", "Endpoint of the member to snapshot (default the first configured endpoint)")
+	timeout := c.flags.Duration("timeout", 30*time.Minute, "Timeout for streaming the snapshot")
+
+	c.run = func(ctx context.Context, e *env, args []string) error {
+		if args[0] != "save" {
+			return usagef("unknown snapshot command %q, want save", args[0])
+		}
+		path := args[1]
+		endpoint := *from
+		if endpoint == "" {
+			endpoints, err := e.endpoints("")
+			if err != nil {
+				return err
+			}
+			if len(endpoints) == 0 {
+				return usagef("no endpoint configured, give --from")
+			}
+			endpoint = endpoints[0]
+		}
+		out, err := e.output()
+		if err != nil {
+			return err
+		}
+
+		// A client of its own so that the snapshot and its metadata come from
+		// the same member
+		cli, err := walker.Connect([]string{endpoint}, e.g.dialTimeout)
+		if err != nil {
+			return fmt.Errorf("failed to connect to %s: %w", endpoint, err)
+		}
+		defer cli.Close()
+
+		log.Printf("Saving a snapshot of %s to %s...", endpoint, path)
+		sctx, cancel := context.WithTimeout(ctx, *timeout)
+		info, err := walker.SaveSnapshot(sctx, cli, path)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to save snapshot '%s': %w", path, err)
+		}
+		rev, err := snapshot.Verify(path)
+		if err != nil {
+			return fmt.Errorf("saved '%s' but it failed verification, do not rely on it: %w", path, err)
+		}
+
+		meta := snapshot.Meta{
+			Endpoint: info.Endpoint,
+			MemberID: info.MemberID,
+			Revision: rev,
+			Size:     info.Size,
+			SHA256:   info.SHA256,
+			Created:  time.Now().UTC(),
+		}
+		if err := snapshot.WriteMeta(path, &meta); err != nil {
+			return err
+		}
+		return out.Write(snapshotRecord{Meta: meta, File: path})
+	}
+	return c
+}
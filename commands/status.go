@@ -0,0 +1,133 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/CedricElie/etcd-walker/output"
+	"github.com/CedricElie/etcd-walker/walker"
+)
+
+// statusRecord is one member's row of the status table.
+type statusRecord struct{ walker.EndpointStatus }
+
+func (r statusRecord) Columns() []string {
+	return []string{"ENDPOINT", "NAME", "ID", "VERSION", "DB_SIZE", "IN_USE", "LEADER", "RAFT_TERM", "RAFT_INDEX", "ALARMS", "LATENCY", "ERROR"}
+}
+func (r statusRecord) Row() []string {
+	return []string{r.Endpoint, r.Name, strconv.FormatUint(r.MemberID, 16), r.Version,
+		humanBytes(r.DBSize), humanBytes(r.DBSizeInUse), strconv.FormatBool(r.IsLeader),
+		strconv.FormatUint(r.RaftTerm, 10), strconv.FormatUint(r.RaftIndex, 10),
+		strings.Join(r.Alarms, ","), r.Latency.Round(time.Microsecond).String(), r.Error}
+}
+func (r statusRecord) Text(color bool) string {
+	name := r.Name
+	if r.IsLeader {
+		name += " (leader)"
+	}
+	if r.Learner {
+		name += " (learner)"
+	}
+	if r.Version == "" {
+		return fmt.Sprintf("%s %x %s: %s", r.Endpoint, r.MemberID, name, output.Highlight(r.Error, color))
+	}
+	s := fmt.Sprintf("%s %x %s: v%s, db %s (%s in use), raft term %d index %d, %s",
+		r.Endpoint, r.MemberID, name, r.Version, humanBytes(r.DBSize), humanBytes(r.DBSizeInUse),
+		r.RaftTerm, r.RaftIndex, r.Latency.Round(time.Microsecond))
+	if len(r.Alarms) > 0 {
+		s += ", " + output.Highlight("alarms "+strings.Join(r.Alarms, ","), color)
+	}
+	if r.Error != "" {
+		s += ", " + output.Highlight(r.Error, color)
+	}
+	return s
+}
+
+// statusSummaryRecord is the verdict on the whole cluster.
+type statusSummaryRecord struct {
+	Healthy  bool     `json:"healthy"`
+	Leader   uint64   `json:"leader"`
+	Members  int      `json:"members"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+func (r statusSummaryRecord) Columns() []string {
+	return []string{"HEALTHY", "LEADER", "MEMBERS", "PROBLEMS"}
+}
+func (r statusSummaryRecord) Row() []string {
+	return []string{strconv.FormatBool(r.Healthy), strconv.FormatUint(r.Leader, 16), strconv.Itoa(r.Members), strings.Join(r.Problems, "; ")}
+}
+func (r statusSummaryRecord) Text(color bool) string {
+	if r.Healthy {
+		return fmt.Sprintf("Cluster is healthy: %d members, leader %x", r.Members, r.Leader)
+	}
+	return output.Highlight("Cluster is unhealthy:", color) + "\n  " + strings.Join(r.Problems, "\n  ")
+}
+
+// errUnhealthy makes status exit with a failure for scripts.
+var errUnhealthy = errors.New("cluster is unhealthy")
+
+// checkHealthy runs Status and fails unless the cluster is healthy, ignoring
+// alarms since compact and defrag are what clears NOSPACE. when says at which
+// step the check was made.
+func checkHealthy(ctx context.Context, e *env, cli *clientv3.Client, useEndpoints bool, when string) (*walker.ClusterStatus, error) {
+	var endpoints []string
+	if useEndpoints {
+		endpoints = cli.Endpoints()
+	}
+	status, err := walker.Status(ctx, cli, endpoints, e.g.commandTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if len(status.Problems) > 0 {
+		return status, fmt.Errorf("%w %s: %s", errUnhealthy, when, strings.Join(status.Problems, "; "))
+	}
+	return status, nil
+}
+
+func statusCommand() *command {
+	c := newCommand("status", "", "Show each member's version, DB size, raft state, alarms and latency; fails when unhealthy", 0, 0)
+	useEndpoints := c.flags.Bool("use-endpoints", false, "Query the configured endpoints instead of each member's advertised client URL")
+
+	c.run = func(ctx context.Context, e *env, args []string) error {
+		cli, err := e.client()
+		if err != nil {
+			return err
+		}
+		out, err := e.output()
+		if err != nil {
+			return err
+		}
+
+		var endpoints []string
+		if *useEndpoints {
+			endpoints = cli.Endpoints()
+		}
+		status, err := walker.Status(ctx, cli, endpoints, e.g.commandTimeout)
+		if err != nil {
+			return err
+		}
+		for _, m := range status.Members {
+			if err := out.Write(statusRecord{m}); err != nil {
+				return err
+			}
+		}
+		err = out.Write(statusSummaryRecord{
+			Healthy:  status.Healthy(),
+			Leader:   status.Leader,
+			Members:  len(status.Members),
+			Problems: append(append([]string(nil), status.Alarms...), status.Problems...),
+		})
+		if err == nil && !status.Healthy() {
+			err = errUnhealthy
+		}
+		return err
+	}
+	return c
+}
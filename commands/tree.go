@@ -0,0 +1,144 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+
+	"github.com/CedricElie/etcd-walker/output"
+	"github.com/CedricElie/etcd-walker/walker"
+)
+
+// treeRecord is one node of the tree. Text output draws the hierarchy;
+// the other formats get one flat record per node.
+type treeRecord struct {
+	Path     string `json:"path"`
+	Depth    int    `json:"depth"`
+	Keys     int    `json:"keys"`
+	Bytes    *int64 `json:"bytes,omitempty"` // nil without --sizes
+	IsKey    bool   `json:"is_key"`
+	name     string
+	branch   string // Box-drawing prefix
+	children bool
+}
+
+func (r treeRecord) Columns() []string { return []string{"PATH", "KEYS", "BYTES"} }
+func (r treeRecord) Row() []string {
+	bytes := ""
+	if r.Bytes != nil {
+		bytes = strconv.FormatInt(*r.Bytes, 10)
+	}
+	return []string{r.Path, strconv.Itoa(r.Keys), bytes}
+}
+func (r treeRecord) Text(color bool) string {
+	size := ""
+	if r.Bytes != nil {
+		size = humanBytes(*r.Bytes)
+	}
+	if !r.children && r.IsKey && r.Keys == 1 {
+		if size == "" {
+			return r.branch + r.name
+		}
+		return fmt.Sprintf("%s%s (%s)", r.branch, r.name, size)
+	}
+	if size == "" {
+		return fmt.Sprintf("%s%s (%s)", r.branch, r.name, keyCount(r.Keys))
+	}
+	return fmt.Sprintf("%s%s (%s, %s)", r.branch, r.name, keyCount(r.Keys), size)
+}
+
+func keyCount(n int) string {
+	if n == 1 {
+		return "1 key"
+	}
+	return fmt.Sprintf("%d keys", n)
+}
+
+// humanBytes formats a byte count with binary units.
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func treeCommand() *command {
+	c := newCommand("tree", "[PREFIX]", "Show the keyspace as a directory tree with key counts, and value sizes with --sizes", 0, 1)
+	c.offline = true
+	depth := c.flags.Int("depth", 0, "Levels to show below PREFIX, 0 for all")
+	sizes := c.flags.Bool("sizes", false, "Fetch the values to total their sizes; without it only keys are read")
+
+	c.run = func(ctx context.Context, e *env, args []string) error {
+		prefix := ""
+		if len(args) > 0 {
+			prefix = args[0]
+		}
+		cli, err := e.client()
+		if err != nil {
+			return err
+		}
+		out, err := e.output()
+		if err != nil {
+			return err
+		}
+
+		// Values are only measured, a page at a time
+		tree := walker.NewTree(prefix, *depth)
+		_, err = walker.Scan(ctx, cli, prefix, walker.ScanOptions{KeysOnly: !*sizes}, func(kv *mvccpb.KeyValue) error {
+			tree.Add(string(kv.Key), len(kv.Value))
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to scan '%s': %w", prefix, err)
+		}
+		return writeTree(out.Write, tree.Root, *sizes)
+	}
+	return c
+}
+
+// writeTree emits root and its descendants depth first.
+func writeTree(write func(output.Record) error, root *walker.Node, sizes bool) error {
+	var walk func(n *walker.Node, depth int, branch, indent string) error
+	walk = func(n *walker.Node, depth int, branch, indent string) error {
+		rec := treeRecord{
+			Path:     n.Path,
+			Depth:    depth,
+			Keys:     n.Keys,
+			IsKey:    n.IsKey,
+			name:     n.Name,
+			branch:   branch,
+			children: len(n.Children) > 0,
+		}
+		if sizes {
+			bytes := n.Bytes
+			rec.Bytes = &bytes
+		}
+		if depth == 0 && rec.name == "" {
+			rec.name = "(all keys)"
+		}
+		if err := write(rec); err != nil {
+			return err
+		}
+
+		children := n.Sorted()
+		for i, child := range children {
+			b, next := "├── ", "│   "
+			if i == len(children)-1 {
+				b, next = "└── ", "    "
+			}
+			if err := walk(child, depth+1, indent+b, indent+next); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(root, 0, "", "")
+}
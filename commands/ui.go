@@ -0,0 +1,23 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/CedricElie/etcd-walker/ui"
+)
+
+func uiCommand() *command {
+	c := newCommand("ui", "[PREFIX]", "Browse the keyspace in a full-screen tree that follows changes live", 0, 1)
+	c.run = func(ctx context.Context, e *env, args []string) error {
+		cli, err := e.client()
+		if err != nil {
+			return err
+		}
+		prefix := "/"
+		if len(args) > 0 {
+			prefix = args[0]
+		}
+		return ui.Run(ctx, cli, prefix, e.g.commandTimeout)
+	}
+	return c
+}
@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/CedricElie/etcd-walker/walker"
+)
+
+// watchRecord is one event seen by watch.
+type watchRecord struct {
+	Revision  int64   `json:"revision"`
+	Type      string  `json:"type"`
+	Key       string  `json:"key"`
+	Value     *string `json:"value,omitempty"`
+	PrevValue *string `json:"prev_value,omitempty"`
+}
+
+func (r watchRecord) Columns() []string {
+	return []string{"REVISION", "TYPE", "KEY", "VALUE", "PREV_VALUE"}
+}
+func (r watchRecord) Row() []string {
+	return []string{strconv.FormatInt(r.Revision, 10), r.Type, r.Key, deref(r.Value), deref(r.PrevValue)}
+}
+func (r watchRecord) Text(color bool) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[%d] %s %s", r.Revision, r.Type, r.Key)
+	if r.Value != nil {
+		fmt.Fprintf(&sb, "\n  value: %s", *r.Value)
+	}
+	if r.PrevValue != nil {
+		fmt.Fprintf(&sb, "\n  previous: %s", *r.PrevValue)
+	}
+	return sb.String()
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func watchCommand() *command {
+	c := newCommand("watch", "PREFIX", "Stream changes under a prefix until interrupted", 1, 1)
+	events := c.flags.String("events", "put,delete", "Event types to show: put, delete or both")
+	pattern := c.flags.String("grep", "", "Only show events whose key or value matches this regular expression")
+	rev := c.flags.Int64("rev", 0, "Revision to start from, to resume an earlier watch")
+	values := c.flags.Bool("values", false, "Show the new value of put events")
+	prev := c.flags.Bool("prev", false, "Show the previous value of each key")
+
+	c.run = func(ctx context.Context, e *env, args []string) error {
+		show := map[mvccpb.Event_EventType]bool{}
+		for _, t := range strings.Split(*events, ",") {
+			switch strings.TrimSpace(t) {
+			case "put":
+				show[mvccpb.PUT] = true
+			case "delete":
+				show[mvccpb.DELETE] = true
+			default:
+				return usagef("unknown event type %q, want put or delete", t)
+			}
+		}
+		var re *regexp.Regexp
+		if *pattern != "" {
+			var err error
+			if re, err = regexp.Compile(*pattern); err != nil {
+				return usagef("invalid pattern: %v", err)
+			}
+		}
+
+		cli, err := e.client()
+		if err != nil {
+			return err
+		}
+		out, err := e.output()
+		if err != nil {
+			return err
+		}
+
+		err = walker.Watch(ctx, cli, args[0], walker.WatchOptions{StartRevision: *rev, PrevKV: *prev || re != nil}, func(ev *clientv3.Event) error {
+			if !show[ev.Type] {
+				return nil
+			}
+			// Deletes have no value, so match them on the previous one
+			if re != nil && !re.Match(ev.Kv.Key) && !re.Match(ev.Kv.Value) && (ev.PrevKv == nil || !re.Match(ev.PrevKv.Value)) {
+				return nil
+			}
+
+			rec := watchRecord{Revision: ev.Kv.ModRevision, Type: ev.Type.String(), Key: string(ev.Kv.Key)}
+			if *values && ev.Type == mvccpb.PUT {
+				v := string(ev.Kv.Value)
+				rec.Value = &v
+			}
+			if *prev && ev.PrevKv != nil {
+				v := string(ev.PrevKv.Value)
+				rec.PrevValue = &v
+			}
+			return out.Write(rec)
+		})
+		if errors.Is(err, context.Canceled) {
+			return nil // Interrupted by the user, the normal way to stop
+		}
+		return err
+	}
+	return c
+}
@@ -1,14 +1,17 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	ETCD_HOST    string `mapstructure:"ETCD_HOST"`
-	TestDataPath string `mapstructure:"testDataPath"`
+	ETCD_HOST    string            `mapstructure:"ETCD_HOST"`
+	TestDataPath string            `mapstructure:"testDataPath"`
+	Contexts     map[string]string `mapstructure:"contexts"` // Name -> comma-separated endpoints
 }
 
 var (
@@ -43,4 +46,34 @@ func GetConfig() *Config {
         cfg = loadedCfg
     })
     return cfg
-}
\ No newline at end of file
+}
+
+// Endpoints resolves a context name to its endpoint list. The empty name is
+// the default cluster given by ETCD_HOST.
+func (c *Config) Endpoints(name string) ([]string, error) {
+	hosts := c.ETCD_HOST
+	if name != "" {
+		var ok bool
+		// viper lower-cases map keys when it reads the file
+		if hosts, ok = c.Contexts[strings.ToLower(name)]; !ok {
+			return nil, fmt.Errorf("unknown context %q", name)
+		}
+	}
+
+	endpoints := SplitEndpoints(hosts)
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no endpoints configured for context %q", name)
+	}
+	return endpoints, nil
+}
+
+// SplitEndpoints splits a comma-separated endpoint list, dropping blanks.
+func SplitEndpoints(hosts string) []string {
+	var endpoints []string
+	for _, h := range strings.Split(hosts, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			endpoints = append(endpoints, h)
+		}
+	}
+	return endpoints
+}
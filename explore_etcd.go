@@ -14,6 +14,8 @@ import (
 	"bazil.org/fuse"
 	"bazil.org/fuse/fs"
 
+	"github.com/CedricElie/etcd-walker/walker"
+
 	// Ensure these Kubernetes imports are present and correctly aliased
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1" // Aliased as metav1
@@ -125,35 +127,21 @@ func (ed EtcdDir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
 		return nil, nil
 	}
 
-	entries := make(map[string]fuse.Dirent)
+	// The splitting is shared with etcd-walker's shell and ui so they show the same tree
+	entries := make(map[string]walker.DirEntry)
 	for _, line := range lines {
-		key := strings.TrimSpace(line)
-		if key == "" {
-			continue
-		}
-
-		relPath := strings.TrimPrefix(key, ed.Path)
-		if relPath == "" {
-			continue
-		}
-
-		parts := strings.Split(relPath, "/")
-		name := parts[0]
-
-		if name == "" {
-			continue
-		}
-
-		if len(parts) > 1 && parts[1] != "" {
-			entries[name] = fuse.Dirent{Name: name, Type: fuse.DT_Dir}
-		} else {
-			entries[name] = fuse.Dirent{Name: name, Type: fuse.DT_File}
+		if entry, ok := walker.SplitKey(ed.Path, strings.TrimSpace(line)); ok {
+			walker.AddEntry(entries, entry)
 		}
 	}
 
 	var dirents []fuse.Dirent
 	for _, entry := range entries {
-		dirents = append(dirents, entry)
+		if entry.Dir {
+			dirents = append(dirents, fuse.Dirent{Name: entry.Name, Type: fuse.DT_Dir})
+		} else {
+			dirents = append(dirents, fuse.Dirent{Name: entry.Name, Type: fuse.DT_File})
+		}
 	}
 	return dirents, nil
 }
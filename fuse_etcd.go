@@ -8,6 +8,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"syscall"
@@ -15,6 +16,11 @@ import (
 
 	"bazil.org/fuse"
 	"bazil.org/fuse/fs"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/CedricElie/etcd-walker/snapshot"
+	"github.com/CedricElie/etcd-walker/walker"
 )
 
 // EtcdFS represents the etcd-backed filesystem.
@@ -174,6 +180,180 @@ func loadEtcdData(filePath string) (map[string]string, error) {
 	return data, nil
 }
 
+// SnapshotFS serves an etcd snapshot or a member's member/snap/db read-only.
+// Values are read when a directory is listed, for the sizes of its files,
+// and when a file is, so nothing of the snapshot is held in memory beyond its
+// key index and the listings the kernel still holds directories of.
+//
+// Keys starting with "/" show up relative to the root, as do the keys
+// without one. When both /a and a exist the one starting with "/" is shown
+// and the other skipped with a warning, and so are keys ending in "/", which
+// cannot be files.
+type SnapshotFS struct {
+	cli *clientv3.Client
+
+	mu     sync.Mutex
+	warned map[string]bool // Keys already warned about
+}
+
+// SnapshotDir is a directory of a SnapshotFS: the keys under prefix, which
+// ends in "/". The root has no prefix and merges "/" and the top level.
+type SnapshotDir struct {
+	fs     *SnapshotFS
+	prefix string
+	root   bool
+
+	mu     sync.Mutex
+	listed map[string]fs.Node // Nodes of the last ReadDirAll, for Lookup
+}
+
+// SnapshotFile is a key of a SnapshotFS.
+type SnapshotFile struct {
+	fs   *SnapshotFS
+	key  string
+	size uint64
+}
+
+func (f *SnapshotFS) Root() (fs.Node, error) {
+	return &SnapshotDir{fs: f, root: true}, nil
+}
+
+// warn logs a skipped key once.
+func (f *SnapshotFS) warn(key, format string, args ...any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.warned[key] {
+		f.warned[key] = true
+		log.Printf("Skipping key %q: "+format, append([]any{key}, args...)...)
+	}
+}
+
+// prefixes are the key prefixes the directory shows, the first winning.
+func (d *SnapshotDir) prefixes() []string {
+	if d.root {
+		return []string{"/", ""}
+	}
+	return []string{d.prefix}
+}
+
+func (d *SnapshotDir) Attr(ctx context.Context, a *fuse.Attr) error {
+	a.Inode = hash(d.prefix)
+	a.Mode = os.ModeDir | 0o555
+	return nil
+}
+
+// Lookup for SnapshotDir. A name that is both a key and a prefix of other
+// keys is a directory, as in ReadDirAll, whose listing answers the names it
+// has seen.
+func (d *SnapshotDir) Lookup(ctx context.Context, name string) (fs.Node, error) {
+	d.mu.Lock()
+	node, ok := d.listed[name]
+	d.mu.Unlock()
+	if ok {
+		return node, nil
+	}
+
+	for _, prefix := range d.prefixes() {
+		key := prefix + name
+		// Count the keys under key + "/" other than that one, without
+		// reading them
+		resp, err := d.fs.cli.Get(ctx, key+"/\x00", clientv3.WithRange(clientv3.GetPrefixRangeEnd(key+"/")), clientv3.WithCountOnly())
+		if err != nil {
+			return nil, err
+		}
+		if resp.Count > 0 {
+			return &SnapshotDir{fs: d.fs, prefix: key + "/"}, nil
+		}
+		if resp, err = d.fs.cli.Get(ctx, key); err != nil {
+			return nil, err
+		}
+		if len(resp.Kvs) > 0 {
+			return &SnapshotFile{fs: d.fs, key: key, size: uint64(len(resp.Kvs[0].Value))}, nil
+		}
+	}
+	return nil, syscall.ENOENT
+}
+
+func (d *SnapshotDir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	listed := make(map[string]fs.Node)
+	var dirents []fuse.Dirent
+	for _, prefix := range d.prefixes() {
+		// The entries of this prefix, a directory replacing the file of the
+		// same name
+		nodes := make(map[string]fs.Node)
+		err := walker.ReadDir(ctx, d.fs.cli, prefix, func(kv *mvccpb.KeyValue, e walker.DirEntry) {
+			key := prefix + e.Name
+			switch {
+			case e.Dir:
+				nodes[e.Name] = &SnapshotDir{fs: d.fs, prefix: key + "/"}
+			case string(kv.Key) != key:
+				// Listed as a file only for the key ending in "/"
+				d.fs.warn(key+"/", "keys ending in / cannot be files")
+			case nodes[e.Name] == nil:
+				nodes[e.Name] = &SnapshotFile{fs: d.fs, key: key, size: uint64(len(kv.Value))}
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(nodes))
+		for name := range nodes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			node := nodes[name]
+			_, dir := node.(*SnapshotDir)
+			if other, ok := listed[name]; ok {
+				if dir {
+					d.fs.warn(prefix+name+"/", "it has the same name as %q", nodeKey(other))
+				} else {
+					d.fs.warn(prefix+name, "it has the same name as %q", nodeKey(other))
+				}
+				continue
+			}
+			listed[name] = node
+			if dir {
+				dirents = append(dirents, fuse.Dirent{Name: name, Type: fuse.DT_Dir})
+			} else {
+				dirents = append(dirents, fuse.Dirent{Name: name, Type: fuse.DT_File})
+			}
+		}
+	}
+
+	d.mu.Lock()
+	d.listed = listed
+	d.mu.Unlock()
+	return dirents, nil
+}
+
+// nodeKey is the key, or for a directory the prefix, that node shows.
+func nodeKey(node fs.Node) string {
+	if dir, ok := node.(*SnapshotDir); ok {
+		return dir.prefix
+	}
+	return node.(*SnapshotFile).key
+}
+
+func (f *SnapshotFile) Attr(ctx context.Context, a *fuse.Attr) error {
+	a.Inode = hash(f.key)
+	a.Mode = 0o444
+	a.Size = f.size
+	return nil
+}
+
+func (f *SnapshotFile) ReadAll(ctx context.Context) ([]byte, error) {
+	resp, err := f.fs.cli.Get(ctx, f.key)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, syscall.ENOENT
+	}
+	return resp.Kvs[0].Value, nil
+}
+
 // Scanner is a simple scanner that reads line by line.
 type Scanner struct {
 	file    *os.File
@@ -214,22 +394,35 @@ func hash(s string) uint64 {
 
 func main() {
 	dataPath := flag.String("data", "", "Path to the etcd data file")
+	snapshotPath := flag.String("snapshot", "", "Path to an etcd snapshot or a member's member/snap/db, mounted read-only instead of --data")
 	mountPoint := flag.String("mount", "", "Mount point for the filesystem")
 	flag.Parse()
 
-	if *dataPath == "" || *mountPoint == "" {
-		fmt.Println("Usage: go run etcdfs.go --data <data_file> --mount <mount_point>")
+	if (*dataPath == "") == (*snapshotPath == "") || *mountPoint == "" {
+		fmt.Println("Usage: go run fuse_etcd.go (--data <data_file> | --snapshot <snapshot.db>) --mount <mount_point>")
 		os.Exit(1)
 	}
 
-	data, err := loadEtcdData(*dataPath)
-	if err != nil {
-		log.Fatalf("Failed to load etcd data: %v", err)
+	var fsys fs.FS
+	var options []fuse.MountOption
+	if *snapshotPath != "" {
+		db, err := snapshot.Open(*snapshotPath)
+		if err != nil {
+			log.Fatalf("Failed to open snapshot: %v", err)
+		}
+		defer db.Close()
+		fsys = &SnapshotFS{cli: db.Client(context.Background()), warned: make(map[string]bool)}
+		// Nothing is ever written back, so say so to the kernel
+		options = append(options, fuse.ReadOnly())
+	} else {
+		data, err := loadEtcdData(*dataPath)
+		if err != nil {
+			log.Fatalf("Failed to load etcd data: %v", err)
+		}
+		fsys = &EtcdFS{data: data}
 	}
 
-	fsys := &EtcdFS{data: data}
-
-	c, err := fuse.Mount(*mountPoint)
+	c, err := fuse.Mount(*mountPoint, options...)
 	if err != nil {
 		log.Fatalf("Failed to mount FUSE filesystem: %v", err)
 	}
@@ -9,8 +9,8 @@ import (
 	"strings"
 	"time"
 
-	"go.etcd.io/etcd/client/v3"
 	"github.com/CedricElie/etcd-walker/config"
+	"github.com/CedricElie/etcd-walker/walker"
 )
 
 var (
@@ -22,17 +22,22 @@ var (
 func main() {
 	cfg := config.GetConfig()
 
-	cli, err := clientv3.New(clientv3.Config {
-		Endpoints:	[]string{cfg.ETCD_HOST},
-		DialTimeout: 5 * time.Second,
-	})
+	cli, err := walker.Connect([]string{cfg.ETCD_HOST}, walker.DefaultDialTimeout)
 	if err != nil {
-		fmt.Println("Error connecting")
+		fmt.Printf("Error connecting: %v\n", err)
 		return
 	} 
 	
 	defer cli.Close()
 
+	// Every put would fail while the cluster is out of space
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	err = walker.CheckNoSpace(ctx, cli)
+	cancel()
+	if err != nil {
+		log.Fatalf("Not loading data: %v", err)
+	}
+
 	file, err := os.Open(filePath)
 	if err != nil {
 		log.Fatalf("Failed to open file: %v", err)
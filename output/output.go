@@ -0,0 +1,182 @@
+// Package output writes command results in the format chosen with -format.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Format is an output encoding.
+type Format string
+
+const (
+	Text      Format = "text"
+	JSON      Format = "json"
+	JSONLines Format = "jsonl"
+	YAML      Format = "yaml"
+	Table     Format = "table"
+)
+
+// Formats lists the accepted formats, for flag help.
+var Formats = []Format{Text, JSON, JSONLines, YAML, Table}
+
+// ParseFormat validates a -format value.
+func ParseFormat(s string) (Format, error) {
+	if slices.Contains(Formats, Format(s)) {
+		return Format(s), nil
+	}
+	return "", fmt.Errorf("unknown format %q, want one of %v", s, Formats)
+}
+
+// Record is one result. JSON, JSON Lines and YAML encode the record itself
+// with encoding/json, so records should carry json tags; text and table use
+// the methods.
+type Record interface {
+	Columns() []string      // Table header
+	Row() []string          // Table cells, in Columns order
+	Text(color bool) string // Plain text line, with ANSI highlighting if color
+}
+
+// Writer encodes a stream of records. Records are written as they arrive, so
+// long scans show output straight away; Close terminates JSON and YAML
+// documents and flushes tables.
+type Writer struct {
+	w      io.Writer
+	file   *os.File // Non-nil when Writer owns the destination
+	format Format
+	color  bool
+	tw     *tabwriter.Writer
+	header []string
+	n      int
+}
+
+// Open creates a writer to path, where "-" is standard output.
+func Open(path string, format Format) (*Writer, error) {
+	if path == "-" || path == "" {
+		w := New(os.Stdout, format)
+		if fi, err := os.Stdout.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+			w.color = format == Text
+		}
+		return w, nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+	w := New(f, format)
+	w.file = f
+	return w, nil
+}
+
+// New creates a writer to w. It never colours text output.
+func New(w io.Writer, format Format) *Writer {
+	return &Writer{w: w, format: format}
+}
+
+// SetColor turns ANSI highlighting of text output on or off, for writers to
+// a terminal that New cannot detect.
+func (w *Writer) SetColor(color bool) {
+	w.color = color && w.format == Text
+}
+
+// Write encodes one record.
+func (w *Writer) Write(r Record) error {
+	defer func() { w.n++ }()
+
+	switch w.format {
+	case JSON:
+		b, err := json.MarshalIndent(r, "  ", "  ")
+		if err != nil {
+			return err
+		}
+		sep := ",\n  "
+		if w.n == 0 {
+			sep = "[\n  "
+		}
+		_, err = fmt.Fprintf(w.w, "%s%s", sep, b)
+		return err
+
+	case JSONLines:
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w.w, "%s\n", b)
+		return err
+
+	case YAML:
+		// A one element list renders as a "- " item of the overall document
+		b, err := yaml.Marshal([]Record{r})
+		if err != nil {
+			return err
+		}
+		_, err = w.w.Write(b)
+		return err
+
+	case Table:
+		if w.tw == nil {
+			w.tw = tabwriter.NewWriter(w.w, 0, 4, 2, ' ', 0)
+		}
+		if cols := r.Columns(); !slices.Equal(cols, w.header) {
+			// A different kind of record starts a new table
+			if w.header != nil {
+				if err := w.tw.Flush(); err != nil {
+					return err
+				}
+				fmt.Fprintln(w.w)
+			}
+			w.header = cols
+			fmt.Fprintln(w.tw, strings.Join(cols, "\t"))
+		}
+		_, err := fmt.Fprintln(w.tw, strings.Join(r.Row(), "\t"))
+		return err
+
+	default:
+		_, err := fmt.Fprintln(w.w, r.Text(w.color))
+		return err
+	}
+}
+
+// Close finishes the document and closes the destination if Open created it.
+func (w *Writer) Close() error {
+	var err error
+	switch w.format {
+	case JSON:
+		if w.n == 0 {
+			_, err = fmt.Fprintln(w.w, "[]")
+		} else {
+			_, err = fmt.Fprintln(w.w, "\n]")
+		}
+	case YAML:
+		if w.n == 0 {
+			_, err = fmt.Fprintln(w.w, "[]")
+		}
+	case Table:
+		if w.tw != nil {
+			err = w.tw.Flush()
+		}
+	}
+
+	if w.file != nil {
+		if cerr := w.file.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Highlight wraps s in bold red when color is set.
+func Highlight(s string, color bool) string {
+	if !color {
+		return s
+	}
+	return "\033[1;31m" + s + "\033[0m"
+}
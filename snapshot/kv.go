@@ -0,0 +1,353 @@
+package snapshot
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	bolt "go.etcd.io/bbolt"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/grpc"
+)
+
+// ErrReadOnly is returned for writes through a snapshot's client.
+var ErrReadOnly = errors.New("a snapshot is read-only")
+
+var (
+	metaBucket         = []byte("meta")
+	finishedCompactKey = []byte("finishedCompactRev")
+)
+
+// version is one change of a key: its revision, and whether it deleted the
+// key. Bucket keys are the 8-byte big-endian main revision, '_', the 8-byte
+// sub revision, and a trailing 't' for deletions.
+type version struct {
+	main, sub int64
+	tombstone bool
+}
+
+func parseVersion(b []byte) (version, bool) {
+	if len(b) < 17 || b[8] != '_' {
+		return version{}, false
+	}
+	v := version{
+		main: int64(binary.BigEndian.Uint64(b)),
+		sub:  int64(binary.BigEndian.Uint64(b[9:])),
+	}
+	v.tombstone = len(b) == 18 && b[17] == 't'
+	return v, true
+}
+
+func (v version) bucketKey() []byte {
+	b := make([]byte, 17, 18)
+	binary.BigEndian.PutUint64(b, uint64(v.main))
+	b[8] = '_'
+	binary.BigEndian.PutUint64(b[9:], uint64(v.sub))
+	if v.tombstone {
+		b = append(b, 't')
+	}
+	return b
+}
+
+// index is the latest change of every key that exists at the latest
+// revision.
+type index struct {
+	keys      []string // Sorted
+	latest    map[string]version
+	rev       int64 // Latest revision
+	compacted int64 // Revisions before this one are gone
+}
+
+// load reads every change once to learn which key it belongs to, since the
+// key bucket is ordered by revision rather than by key. Only the key of each
+// change is decoded and only the latest revision of each live key is kept,
+// so the index grows with the keys rather than with the history.
+func (d *DB) load() error {
+	d.once.Do(func() {
+		idx := &index{latest: make(map[string]version)}
+		d.err = d.bolt.View(func(tx *bolt.Tx) error {
+			b := tx.Bucket(keyBucket)
+			if b == nil {
+				return fmt.Errorf("not an etcd database, there is no %q bucket", keyBucket)
+			}
+			err := changes(b, 0, func(key []byte, ver version) {
+				if ver.tombstone {
+					delete(idx.latest, string(key))
+				} else {
+					idx.latest[string(key)] = ver
+				}
+				idx.rev = ver.main
+			})
+			if err != nil {
+				return err
+			}
+			// Compacting can drop the newest changes, so like etcd when it
+			// restores, take the compaction revision as the latest if it is
+			// newer than every change left
+			idx.compacted = compactRevision(tx)
+			idx.rev = max(idx.rev, idx.compacted)
+			return nil
+		})
+		idx.keys = make([]string, 0, len(idx.latest))
+		for key := range idx.latest {
+			idx.keys = append(idx.keys, key)
+		}
+		sort.Strings(idx.keys)
+		d.index = idx
+	})
+	return d.err
+}
+
+// changes calls fn for every change in the key bucket, oldest first, up to
+// and including revision upTo unless it is 0.
+func changes(b *bolt.Bucket, upTo int64, fn func(key []byte, ver version)) error {
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		ver, ok := parseVersion(k)
+		if !ok {
+			continue
+		}
+		if upTo > 0 && ver.main > upTo {
+			break
+		}
+		key, err := keyOf(v)
+		if err != nil {
+			return fmt.Errorf("revision %d: %w", ver.main, err)
+		}
+		fn(key, ver)
+	}
+	return nil
+}
+
+// keyOf returns the key of an encoded mvccpb.KeyValue without decoding the
+// rest, the value in particular. Every field of a KeyValue is either a
+// varint or length-delimited, and the key is field 1.
+func keyOf(b []byte) ([]byte, error) {
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errBadKeyValue
+		}
+		b = b[n:]
+		switch tag & 7 {
+		case 0:
+			if _, n = binary.Uvarint(b); n <= 0 {
+				return nil, errBadKeyValue
+			}
+			b = b[n:]
+		case 2:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || l > uint64(len(b)-n) {
+				return nil, errBadKeyValue
+			}
+			if tag>>3 == 1 {
+				return b[n : n+int(l)], nil
+			}
+			b = b[n+int(l):]
+		default:
+			return nil, errBadKeyValue
+		}
+	}
+	// An empty key is left out of the encoding
+	return nil, nil
+}
+
+var errBadKeyValue = errors.New("malformed key-value")
+
+// inRange tells whether key is selected by the key and range end of r.
+func inRange(r *etcdserverpb.RangeRequest, key string) bool {
+	switch end := string(r.RangeEnd); end {
+	case "":
+		return key == string(r.Key)
+	case "\x00":
+		return key >= string(r.Key)
+	default:
+		return key >= string(r.Key) && key < end
+	}
+}
+
+// found returns the keys of r that exist at rev with their latest change
+// then, sorted by key. The index answers for the latest revision; older ones
+// replay the changes up to rev, which costs a read of that much history.
+func (idx *index) found(b *bolt.Bucket, r *etcdserverpb.RangeRequest, rev int64) ([]string, map[string]version, error) {
+	if rev == idx.rev {
+		var keys []string
+		for _, key := range idx.keys[sort.SearchStrings(idx.keys, string(r.Key)):] {
+			if !inRange(r, key) {
+				break
+			}
+			keys = append(keys, key)
+		}
+		return keys, idx.latest, nil
+	}
+
+	then := make(map[string]version)
+	err := changes(b, rev, func(key []byte, ver version) {
+		switch {
+		case !inRange(r, string(key)):
+		case ver.tombstone:
+			delete(then, string(key))
+		default:
+			then[string(key)] = ver
+		}
+	})
+	keys := make([]string, 0, len(then))
+	for key := range then {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, then, err
+}
+
+// Client returns a client whose KV API reads from the database, as of any
+// revision it still holds, and rejects writes. The other APIs are not set.
+// The first request reads the whole history once to index the keys; reads
+// at an older revision than the latest read the history up to it again.
+func (d *DB) Client(ctx context.Context) *clientv3.Client {
+	cli := clientv3.NewCtxClient(ctx)
+	cli.KV = clientv3.NewKVFromKVClient(kvClient{d}, cli)
+	return cli
+}
+
+// kvClient answers the KV gRPC API from the database, so that clientv3 does
+// the option handling exactly as it would against a cluster.
+type kvClient struct{ d *DB }
+
+func (c kvClient) Range(ctx context.Context, r *etcdserverpb.RangeRequest, _ ...grpc.CallOption) (*etcdserverpb.RangeResponse, error) {
+	if err := c.d.load(); err != nil {
+		return nil, err
+	}
+	if len(r.Key) == 0 {
+		return nil, rpctypes.ErrGRPCEmptyKey
+	}
+	idx := c.d.index
+	rev := r.Revision
+	if rev <= 0 {
+		rev = idx.rev
+	}
+	switch {
+	case rev > idx.rev:
+		return nil, rpctypes.ErrGRPCFutureRev
+	case rev < idx.compacted:
+		return nil, rpctypes.ErrGRPCCompacted
+	}
+
+	tx, err := c.d.bolt.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	type match struct {
+		key string
+		ver version
+		kv  *mvccpb.KeyValue
+	}
+	var matches []*match
+	fetch := func(m *match) *mvccpb.KeyValue {
+		if m.kv == nil && err == nil {
+			m.kv, err = value(tx, m.ver)
+		}
+		if m.kv == nil {
+			return &mvccpb.KeyValue{}
+		}
+		return m.kv
+	}
+
+	keys, versions, err := idx.found(tx.Bucket(keyBucket), r, rev)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		ver := versions[key]
+		if (r.MinModRevision > 0 && ver.main < r.MinModRevision) || (r.MaxModRevision > 0 && ver.main > r.MaxModRevision) {
+			continue
+		}
+		m := &match{key: key, ver: ver}
+		if r.MinCreateRevision > 0 || r.MaxCreateRevision > 0 {
+			create := fetch(m).CreateRevision
+			if (r.MinCreateRevision > 0 && create < r.MinCreateRevision) || (r.MaxCreateRevision > 0 && create > r.MaxCreateRevision) {
+				continue
+			}
+		}
+		matches = append(matches, m)
+	}
+
+	// Like etcd, a sort target without an order sorts ascending
+	order := r.SortOrder
+	if order == etcdserverpb.RangeRequest_NONE && r.SortTarget != etcdserverpb.RangeRequest_KEY {
+		order = etcdserverpb.RangeRequest_ASCEND
+	}
+	if order != etcdserverpb.RangeRequest_NONE {
+		less := func(a, b *match) bool {
+			switch r.SortTarget {
+			case etcdserverpb.RangeRequest_VERSION:
+				return fetch(a).Version < fetch(b).Version
+			case etcdserverpb.RangeRequest_CREATE:
+				return fetch(a).CreateRevision < fetch(b).CreateRevision
+			case etcdserverpb.RangeRequest_MOD:
+				return a.ver.main < b.ver.main
+			case etcdserverpb.RangeRequest_VALUE:
+				return string(fetch(a).Value) < string(fetch(b).Value)
+			default:
+				return a.key < b.key
+			}
+		}
+		sort.SliceStable(matches, func(i, j int) bool {
+			if order == etcdserverpb.RangeRequest_DESCEND {
+				return less(matches[j], matches[i])
+			}
+			return less(matches[i], matches[j])
+		})
+	}
+
+	resp := &etcdserverpb.RangeResponse{Header: &etcdserverpb.ResponseHeader{Revision: idx.rev}, Count: int64(len(matches))}
+	if r.CountOnly {
+		return resp, err
+	}
+	if r.Limit > 0 && int64(len(matches)) > r.Limit {
+		matches, resp.More = matches[:r.Limit], true
+	}
+	for _, m := range matches {
+		kv := fetch(m)
+		if r.KeysOnly {
+			kv.Value = nil
+		}
+		resp.Kvs = append(resp.Kvs, kv)
+	}
+	return resp, err
+}
+
+// value reads the key and value of a change.
+func value(tx *bolt.Tx, ver version) (*mvccpb.KeyValue, error) {
+	v := tx.Bucket(keyBucket).Get(ver.bucketKey())
+	if v == nil {
+		return nil, fmt.Errorf("revision %d is missing", ver.main)
+	}
+	kv := &mvccpb.KeyValue{}
+	if err := kv.Unmarshal(v); err != nil {
+		return nil, fmt.Errorf("revision %d: %w", ver.main, err)
+	}
+	return kv, nil
+}
+
+func (c kvClient) Put(context.Context, *etcdserverpb.PutRequest, ...grpc.CallOption) (*etcdserverpb.PutResponse, error) {
+	return nil, ErrReadOnly
+}
+
+func (c kvClient) DeleteRange(context.Context, *etcdserverpb.DeleteRangeRequest, ...grpc.CallOption) (*etcdserverpb.DeleteRangeResponse, error) {
+	return nil, ErrReadOnly
+}
+
+func (c kvClient) Txn(context.Context, *etcdserverpb.TxnRequest, ...grpc.CallOption) (*etcdserverpb.TxnResponse, error) {
+	return nil, ErrReadOnly
+}
+
+func (c kvClient) Compact(context.Context, *etcdserverpb.CompactionRequest, ...grpc.CallOption) (*etcdserverpb.CompactionResponse, error) {
+	return nil, ErrReadOnly
+}
@@ -0,0 +1,234 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/CedricElie/etcd-walker/snapshot/snapshottest"
+)
+
+func TestParseVersion(t *testing.T) {
+	for _, want := range []version{{main: 2}, {main: 1 << 40, sub: 3}, {main: 7, tombstone: true}} {
+		got, ok := parseVersion(want.bucketKey())
+		if !ok || got != want {
+			t.Errorf("parseVersion(%q) = %+v, %v, want %+v", want.bucketKey(), got, ok, want)
+		}
+	}
+	for _, b := range []string{"", "finishedCompactRev", "\x00\x00\x00\x00\x00\x00\x00\x02-\x00\x00\x00\x00\x00\x00\x00\x00"} {
+		if v, ok := parseVersion([]byte(b)); ok {
+			t.Errorf("parseVersion(%q) = %+v, want not a revision", b, v)
+		}
+	}
+}
+
+func TestKeyOf(t *testing.T) {
+	tests := []*mvccpb.KeyValue{
+		{Key: []byte("/a"), Value: []byte("v"), CreateRevision: 2, ModRevision: 300, Version: 4, Lease: 1 << 60},
+		{Key: []byte("k"), Lease: 5},
+		{Value: []byte("no key")},
+	}
+	for _, kv := range tests {
+		b, err := kv.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := keyOf(b); err != nil || string(got) != string(kv.Key) {
+			t.Errorf("keyOf(%v) = %q, %v, want %q", kv, got, err, kv.Key)
+		}
+	}
+	for _, b := range []string{"\x0a\x05a", "\x10", "\x0d\x00\x00\x00\x00"} {
+		if _, err := keyOf([]byte(b)); !errors.Is(err, errBadKeyValue) {
+			t.Errorf("keyOf(%q) = %v, want %v", b, err, errBadKeyValue)
+		}
+	}
+}
+
+// open returns a client of the database at path.
+func open(t *testing.T, path string) *clientv3.Client {
+	t.Helper()
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db.Client(context.Background())
+}
+
+func keys(resp *clientv3.GetResponse) []string {
+	var keys []string
+	for _, kv := range resp.Kvs {
+		keys = append(keys, string(kv.Key))
+	}
+	return keys
+}
+
+func TestRange(t *testing.T) {
+	b := snapshottest.New(t)
+	a1 := b.Put("a", "1")
+	b.Put("b", "1")
+	a2 := b.Put("a", "2")
+	bDeleted := b.Delete("b")
+	b.Put("c/x", "3")
+	last := b.Put("c/y", "4")
+	cli := open(t, b.Close())
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		key   string
+		opts  []clientv3.OpOption
+		want  []string
+		count int64
+		more  bool
+	}{
+		{"one key", "a", nil, []string{"a"}, 1, false},
+		{"deleted key", "b", nil, nil, 0, false},
+		{"deleted key before it was deleted", "b", []clientv3.OpOption{clientv3.WithRev(bDeleted - 1)}, []string{"b"}, 1, false},
+		{"prefix", "c/", []clientv3.OpOption{clientv3.WithPrefix()}, []string{"c/x", "c/y"}, 2, false},
+		{"everything", "\x00", []clientv3.OpOption{clientv3.WithFromKey()}, []string{"a", "c/x", "c/y"}, 3, false},
+		{"range", "a", []clientv3.OpOption{clientv3.WithRange("c/y")}, []string{"a", "c/x"}, 2, false},
+		{"limit", "c/", []clientv3.OpOption{clientv3.WithPrefix(), clientv3.WithLimit(1)}, []string{"c/x"}, 2, true},
+		{"sorted descending", "\x00", []clientv3.OpOption{clientv3.WithFromKey(), clientv3.WithSort(clientv3.SortByKey, clientv3.SortDescend)}, []string{"c/y", "c/x", "a"}, 3, false},
+		{"sorted by mod revision", "\x00", []clientv3.OpOption{clientv3.WithFromKey(), clientv3.WithSort(clientv3.SortByModRevision, clientv3.SortNone)}, []string{"a", "c/x", "c/y"}, 3, false},
+		{"history", "\x00", []clientv3.OpOption{clientv3.WithFromKey(), clientv3.WithRev(a2)}, []string{"a", "b"}, 2, false},
+		{"min mod revision", "\x00", []clientv3.OpOption{clientv3.WithFromKey(), clientv3.WithMinModRev(last)}, []string{"c/y"}, 1, false},
+		{"max create revision", "\x00", []clientv3.OpOption{clientv3.WithFromKey(), clientv3.WithMaxCreateRev(a1)}, []string{"a"}, 1, false},
+		{"count only", "\x00", []clientv3.OpOption{clientv3.WithFromKey(), clientv3.WithCountOnly()}, nil, 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := cli.Get(ctx, tt.key, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := keys(resp); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("keys = %q, want %q", got, tt.want)
+			}
+			if resp.Count != tt.count || resp.More != tt.more {
+				t.Errorf("count, more = %d, %v, want %d, %v", resp.Count, resp.More, tt.count, tt.more)
+			}
+			if resp.Header.Revision != last {
+				t.Errorf("header revision = %d, want %d", resp.Header.Revision, last)
+			}
+		})
+	}
+
+	resp, err := cli.Get(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &mvccpb.KeyValue{Key: []byte("a"), Value: []byte("2"), CreateRevision: a1, ModRevision: a2, Version: 2}
+	if !reflect.DeepEqual(resp.Kvs[0], want) {
+		t.Errorf("get a = %v, want %v", resp.Kvs[0], want)
+	}
+	if resp, err = cli.Get(ctx, "a", clientv3.WithRev(a1)); err != nil || string(resp.Kvs[0].Value) != "1" {
+		t.Errorf("get a at %d = %v, %v, want value 1", a1, resp.Kvs, err)
+	}
+	if resp, err = cli.Get(ctx, "a", clientv3.WithKeysOnly()); err != nil || resp.Kvs[0].Value != nil || resp.Kvs[0].ModRevision != a2 {
+		t.Errorf("get a keys only = %v, %v, want no value", resp.Kvs, err)
+	}
+
+	if _, err := cli.Get(ctx, ""); !errors.Is(err, rpctypes.ErrEmptyKey) {
+		t.Errorf("get of the empty key = %v, want %v", err, rpctypes.ErrEmptyKey)
+	}
+	if _, err := cli.Get(ctx, "a", clientv3.WithRev(last+1)); !errors.Is(err, rpctypes.ErrFutureRev) {
+		t.Errorf("get at a future revision = %v, want %v", err, rpctypes.ErrFutureRev)
+	}
+	if _, err := cli.Put(ctx, "a", "3"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("put = %v, want %v", err, ErrReadOnly)
+	}
+	if _, err := cli.Delete(ctx, "a"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("delete = %v, want %v", err, ErrReadOnly)
+	}
+}
+
+func TestRangeCompacted(t *testing.T) {
+	b := snapshottest.New(t)
+	b.Put("a", "1")
+	b.Put("b", "1")
+	b.Put("a", "2")
+	deleted := b.Delete("b")
+	last := b.Put("c", "1")
+	b.Compact(deleted)
+	cli := open(t, b.Close())
+	ctx := context.Background()
+
+	if _, err := cli.Get(ctx, "a", clientv3.WithRev(deleted-1)); !errors.Is(err, rpctypes.ErrCompacted) {
+		t.Errorf("get before the compaction = %v, want %v", err, rpctypes.ErrCompacted)
+	}
+	resp, err := cli.Get(ctx, "\x00", clientv3.WithFromKey(), clientv3.WithRev(deleted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := keys(resp), []string{"a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("keys at the compaction = %q, want %q", got, want)
+	}
+	resp, err = cli.Get(ctx, "\x00", clientv3.WithFromKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := keys(resp), []string{"a", "c"}; !reflect.DeepEqual(got, want) || resp.Header.Revision != last {
+		t.Errorf("keys = %q at %d, want %q at %d", got, resp.Header.Revision, want, last)
+	}
+	if string(resp.Kvs[0].Value) != "2" {
+		t.Errorf("a = %q, want 2", resp.Kvs[0].Value)
+	}
+}
+
+func TestRangeCompactedNewest(t *testing.T) {
+	// Compacting drops the deletion of b, the newest change in the bucket
+	b := snapshottest.New(t)
+	b.Put("a", "1")
+	b.Put("b", "1")
+	deleted := b.Delete("b")
+	b.Compact(deleted)
+	cli := open(t, b.Close())
+
+	resp, err := cli.Get(context.Background(), "\x00", clientv3.WithFromKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := keys(resp), []string{"a"}; !reflect.DeepEqual(got, want) || resp.Header.Revision != deleted {
+		t.Errorf("keys = %q at %d, want %q at %d", got, resp.Header.Revision, want, deleted)
+	}
+}
+
+func TestRevision(t *testing.T) {
+	b := snapshottest.New(t)
+	b.Put("a", "1")
+	last := b.Delete("a")
+	db, err := Open(b.Close())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if rev, err := db.Revision(); err != nil || rev != last {
+		t.Errorf("Revision() = %d, %v, want %d", rev, err, last)
+	}
+	if err := db.Check(); err != nil {
+		t.Errorf("Check() = %v", err)
+	}
+}
+
+func TestRevisionCompacted(t *testing.T) {
+	// Compacting drops the deletion of b, the newest change in the bucket
+	b := snapshottest.New(t)
+	b.Put("a", "1")
+	b.Put("b", "1")
+	deleted := b.Delete("b")
+	b.Compact(deleted)
+	db, err := Open(b.Close())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if rev, err := db.Revision(); err != nil || rev != deleted {
+		t.Errorf("Revision() = %d, %v, want %d", rev, err, deleted)
+	}
+}
@@ -0,0 +1,185 @@
+// Package snapshot reads etcd database files, either snapshots saved through
+// the Maintenance API or a member's member/snap/db, without a running cluster,
+// and serves their keys through a read-only client.
+package snapshot
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// keyBucket holds every revision of every key, keyed by revision.
+var keyBucket = []byte("key")
+
+// ErrHashMismatch is returned by Verify when a snapshot does not match the
+// checksum etcd appended to it.
+var ErrHashMismatch = errors.New("snapshot checksum mismatch")
+
+// DB is an etcd database opened read-only.
+type DB struct {
+	bolt *bolt.DB
+
+	once  sync.Once // Loads index on first use
+	index *index
+	err   error
+}
+
+// Open opens an etcd database read-only: a snapshot file, a member's
+// member/snap/db, or a member's data directory holding it. A member that is
+// running holds a lock on its database, so it has to be copied or the member
+// stopped first.
+func Open(path string) (*DB, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		path = filepath.Join(path, "member", "snap", "db")
+	}
+	db, err := bolt.Open(path, 0o400, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("%s is locked by a running etcd, copy it or stop the member first", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	return &DB{bolt: db}, nil
+}
+
+// Close closes the database.
+func (d *DB) Close() error {
+	return d.bolt.Close()
+}
+
+// Revision returns the latest revision in the database. It is at least the
+// compaction revision, since compacting can drop the newest changes, the
+// deletions of keys, from the key bucket.
+func (d *DB) Revision() (int64, error) {
+	var rev int64
+	err := d.bolt.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(keyBucket)
+		if b == nil {
+			return fmt.Errorf("not an etcd database, there is no %q bucket", keyBucket)
+		}
+		if k, _ := b.Cursor().Last(); k != nil {
+			rev = int64(binary.BigEndian.Uint64(k))
+		}
+		rev = max(rev, compactRevision(tx))
+		return nil
+	})
+	return rev, err
+}
+
+// compactRevision returns the revision the database was compacted at, 0 if
+// it never was.
+func compactRevision(tx *bolt.Tx) int64 {
+	if meta := tx.Bucket(metaBucket); meta != nil {
+		if ver, ok := parseVersion(meta.Get(finishedCompactKey)); ok {
+			return ver.main
+		}
+	}
+	return 0
+}
+
+// Check walks the whole database and returns the first inconsistency found.
+func (d *DB) Check() error {
+	return d.bolt.View(func(tx *bolt.Tx) error {
+		var first error
+		for err := range tx.Check() {
+			if first == nil {
+				first = err
+			}
+		}
+		return first
+	})
+}
+
+// Verify checks a database file and returns its latest revision. Snapshots
+// streamed by etcd end with the SHA-256 of the database, which must match;
+// member databases have none. Either way the file must be a consistent
+// bbolt database holding etcd keys.
+func Verify(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	// bbolt files are a whole number of pages, so a remainder of exactly a
+	// hash is the checksum etcd appends
+	if size := fi.Size(); size%512 == sha256.Size {
+		h := sha256.New()
+		if _, err := io.CopyN(h, f, size-sha256.Size); err != nil {
+			return 0, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		want := make([]byte, sha256.Size)
+		if _, err := io.ReadFull(f, want); err != nil {
+			return 0, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if !bytes.Equal(h.Sum(nil), want) {
+			return 0, fmt.Errorf("%w: %s is corrupt or truncated", ErrHashMismatch, path)
+		}
+	}
+
+	db, err := Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	if err := db.Check(); err != nil {
+		return 0, fmt.Errorf("%s is corrupt: %w", path, err)
+	}
+	return db.Revision()
+}
+
+// Meta is the sidecar saved next to a snapshot to tell where it comes from.
+type Meta struct {
+	Endpoint string    `json:"endpoint"`
+	MemberID uint64    `json:"member_id"`
+	Revision int64     `json:"revision"`
+	Size     int64     `json:"size"`
+	SHA256   string    `json:"sha256"` // Of the whole file
+	Created  time.Time `json:"created"`
+}
+
+// MetaPath is where the sidecar of the snapshot at path goes.
+func MetaPath(path string) string {
+	return path + ".json"
+}
+
+// WriteMeta writes the sidecar of the snapshot at path and syncs it.
+func WriteMeta(path string, m *Meta) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(MetaPath(path), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot metadata: %w", err)
+	}
+	_, err = f.Write(append(b, '\n'))
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot metadata: %w", err)
+	}
+	return nil
+}
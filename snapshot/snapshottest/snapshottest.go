@@ -0,0 +1,148 @@
+// Package snapshottest writes etcd database files for tests of code that
+// reads snapshots, without running etcd.
+package snapshottest
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+)
+
+var (
+	keyBucket          = []byte("key")
+	metaBucket         = []byte("meta")
+	finishedCompactKey = []byte("finishedCompactRev")
+)
+
+// DB is an etcd database being written in etcd's on-disk layout. Every Put
+// and Delete is a revision of its own, the first one 2 as in a new cluster.
+type DB struct {
+	t    testing.TB
+	path string
+	bolt *bolt.DB
+	rev  int64
+	live map[string]*mvccpb.KeyValue
+}
+
+// New creates an empty database in a temporary directory of t.
+func New(t testing.TB) *DB {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "db")
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucket(keyBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(metaBucket)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &DB{t: t, path: path, bolt: db, rev: 1, live: make(map[string]*mvccpb.KeyValue)}
+}
+
+// Put sets key to value and returns the revision of the change.
+func (d *DB) Put(key, value string) int64 {
+	d.t.Helper()
+	d.rev++
+	kv := &mvccpb.KeyValue{Key: []byte(key), Value: []byte(value), ModRevision: d.rev, CreateRevision: d.rev, Version: 1}
+	if prev := d.live[key]; prev != nil {
+		kv.CreateRevision, kv.Version = prev.CreateRevision, prev.Version+1
+	}
+	d.live[key] = kv
+	d.write(revKey(d.rev, false), kv)
+	return d.rev
+}
+
+// Delete deletes key, which must exist, and returns the revision of the
+// change.
+func (d *DB) Delete(key string) int64 {
+	d.t.Helper()
+	if d.live[key] == nil {
+		d.t.Fatalf("delete of %q, which does not exist", key)
+	}
+	d.rev++
+	delete(d.live, key)
+	d.write(revKey(d.rev, true), &mvccpb.KeyValue{Key: []byte(key)})
+	return d.rev
+}
+
+// Compact drops the changes before rev that are no longer visible at rev,
+// as etcd does, and records rev as the compaction revision.
+func (d *DB) Compact(rev int64) {
+	d.t.Helper()
+	err := d.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(keyBucket)
+		latest := make(map[string][]byte) // Bucket key of each key's change at rev
+		var drop [][]byte
+		c := b.Cursor()
+		for k, v := c.First(); k != nil && int64(binary.BigEndian.Uint64(k)) <= rev; k, v = c.Next() {
+			kv := &mvccpb.KeyValue{}
+			if err := kv.Unmarshal(v); err != nil {
+				return err
+			}
+			if prev, ok := latest[string(kv.Key)]; ok {
+				drop = append(drop, prev)
+			}
+			latest[string(kv.Key)] = append([]byte(nil), k...)
+		}
+		for _, k := range latest {
+			if len(k) == 18 { // A tombstone, the key is gone
+				drop = append(drop, k)
+			}
+		}
+		for _, k := range drop {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(metaBucket).Put(finishedCompactKey, revKey(rev, false))
+	})
+	if err != nil {
+		d.t.Fatal(err)
+	}
+}
+
+// Close closes the database, so that it can be opened read-only, and
+// returns its path.
+func (d *DB) Close() string {
+	d.t.Helper()
+	if err := d.bolt.Close(); err != nil {
+		d.t.Fatal(err)
+	}
+	return d.path
+}
+
+func (d *DB) write(k []byte, kv *mvccpb.KeyValue) {
+	d.t.Helper()
+	v, err := kv.Marshal()
+	if err != nil {
+		d.t.Fatal(err)
+	}
+	err = d.bolt.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(keyBucket).Put(k, v)
+	})
+	if err != nil {
+		d.t.Fatal(err)
+	}
+}
+
+// revKey is the bucket key of a change: the 8-byte big-endian main revision,
+// '_', the 8-byte sub revision, and a trailing 't' for deletions.
+func revKey(main int64, tombstone bool) []byte {
+	b := make([]byte, 17, 18)
+	binary.BigEndian.PutUint64(b, uint64(main))
+	b[8] = '_'
+	if tombstone {
+		b = append(b, 't')
+	}
+	return b
+}
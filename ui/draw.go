@@ -0,0 +1,152 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+
+	"github.com/CedricElie/etcd-walker/walker"
+)
+
+var (
+	styleBar    = tcell.StyleDefault.Reverse(true)
+	styleSel    = tcell.StyleDefault.Reverse(true)
+	styleDimSel = tcell.StyleDefault.Underline(true)
+	styleDir    = tcell.StyleDefault.Foreground(tcell.ColorBlue).Bold(true)
+	styleHeader = tcell.StyleDefault.Foreground(tcell.ColorYellow)
+)
+
+// draw renders the whole screen: a title bar, the tree on the left, the
+// value on the right and a status or search line at the bottom.
+func (b *browser) draw() {
+	s := b.screen
+	s.Clear()
+	width, height := s.Size()
+	body := max(height-2, 1)
+	treeWidth := max(width*2/5, 20)
+
+	fill(s, 0, 0, width, styleBar)
+	drawText(s, 1, 0, width, styleBar, fmt.Sprintf("etcd-walker ui  %s  (%s)", b.root.path, b.watching))
+
+	// Keep the selection on screen
+	if b.sel < b.top {
+		b.top = b.sel
+	}
+	if b.sel >= b.top+body {
+		b.top = b.sel - body + 1
+	}
+	for y := 0; y < body && b.top+y < len(b.rows); y++ {
+		i := b.top + y
+		n := b.rows[i]
+		style, label := tcell.StyleDefault, "  "+n.name
+		if n.dir {
+			style, label = styleDir, "▸ "+n.name+"/"
+			if n.open {
+				label = "▾ " + n.name + "/"
+			}
+			if n.truncated {
+				label += fmt.Sprintf(" (first %d)", maxEntries)
+			}
+		}
+		label = strings.Repeat("  ", n.depth) + label
+		if i == b.sel {
+			style = styleSel
+			if b.focusValue {
+				style = styleDimSel
+			}
+			fill(s, 0, y+1, treeWidth, style)
+		}
+		drawText(s, 0, y+1, treeWidth, style, label)
+	}
+
+	for y := 1; y <= body; y++ {
+		s.SetContent(treeWidth, y, '│', nil, tcell.StyleDefault)
+	}
+	for y := 0; y < body && b.valueTop+y < len(b.valueLines); y++ {
+		style := tcell.StyleDefault
+		if b.value != nil && b.valueTop+y < headerLines {
+			style = styleHeader
+		}
+		drawText(s, treeWidth+2, y+1, width, style, b.valueLines[b.valueTop+y])
+	}
+
+	switch {
+	case b.searching:
+		drawText(s, 0, height-1, width, tcell.StyleDefault, "/"+b.input)
+		s.ShowCursor(runewidth.StringWidth(b.input)+1, height-1)
+	default:
+		s.HideCursor()
+		help := "↑↓ move  →← open/close  Tab value  / search  n/N next  r reload  q quit"
+		if b.focusValue {
+			help = "↑↓ PgUp PgDn scroll  Tab/← back to the tree  q back"
+		}
+		line := help
+		if b.status != "" {
+			line = b.status + "  |  " + help
+		}
+		drawText(s, 0, height-1, width, tcell.StyleDefault.Dim(true), line)
+	}
+	s.Show()
+}
+
+// headerLines is the number of lines valueLines puts before the value.
+const headerLines = 4
+
+// valueLines renders a key for the value pane: its metadata, then JSON
+// pretty-printed, text as it is, or a hex dump of binary values.
+func valueLines(kv *mvccpb.KeyValue) []string {
+	v := kv.Value
+	encoding := walker.Encoding(v)
+	var body string
+	switch encoding {
+	case walker.EncodingEmpty:
+	case walker.EncodingJSON:
+		var buf bytes.Buffer
+		json.Indent(&buf, bytes.TrimSpace(v), "", "  ")
+		body = buf.String()
+	case walker.EncodingText:
+		body = string(v)
+	default:
+		body = hex.Dump(v)
+	}
+
+	lines := []string{
+		string(kv.Key),
+		fmt.Sprintf("create rev %d  mod rev %d  version %d", kv.CreateRevision, kv.ModRevision, kv.Version),
+		fmt.Sprintf("%d bytes, %s, lease %x", len(v), encoding, kv.Lease),
+		"",
+	}
+	body = strings.ReplaceAll(strings.TrimSuffix(body, "\n"), "\t", "    ")
+	if body != "" {
+		lines = append(lines, strings.Split(body, "\n")...)
+	}
+	return lines
+}
+
+// drawText writes s from x up to maxX, replacing control characters.
+func drawText(s tcell.Screen, x, y, maxX int, style tcell.Style, text string) {
+	for _, r := range text {
+		if r < 0x20 || r == 0x7f {
+			r = '.'
+		}
+		w := runewidth.RuneWidth(r)
+		if x+w > maxX {
+			return
+		}
+		s.SetContent(x, y, r, nil, style)
+		x += w
+	}
+}
+
+// fill paints a row from x to maxX, for bars and the selection.
+func fill(s tcell.Screen, x, y, maxX int, style tcell.Style) {
+	for ; x < maxX; x++ {
+		s.SetContent(x, y, ' ', nil, style)
+	}
+}
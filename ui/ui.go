@@ -0,0 +1,550 @@
+// Package ui implements the full-screen keyspace browser behind
+// etcd-walker ui.
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/CedricElie/etcd-walker/walker"
+)
+
+// maxEntries caps the names loaded for one directory, and maxMatches the
+// keys a search collects. refreshRetry is how long to wait before posting a
+// refresh again when the event queue was full.
+const (
+	maxEntries   = 10000
+	maxMatches   = 1000
+	refreshRetry = 100 * time.Millisecond
+)
+
+// node is a row of the key tree. Directories load their children the first
+// time they are opened.
+type node struct {
+	name      string
+	path      string // The key, or for directories the prefix ending in "/"
+	dir       bool
+	depth     int
+	parent    *node
+	open      bool
+	loaded    bool
+	truncated bool // More than maxEntries names
+	children  []*node
+}
+
+// refreshEvent is posted by the watch when keys changed, and watchEndEvent
+// when it stopped.
+type (
+	refreshEvent  struct{}
+	watchEndEvent struct{ err error }
+)
+
+// browser is the state of the UI. Everything but pending is only touched by
+// the event loop.
+type browser struct {
+	ctx     context.Context
+	cli     *clientv3.Client
+	timeout time.Duration
+	screen  tcell.Screen
+
+	root *node
+	rows []*node // Visible tree rows
+	sel  int
+	top  int
+
+	value      *mvccpb.KeyValue // Selected key, nil for directories
+	valueLines []string
+	valueTop   int
+	focusValue bool
+
+	searching bool // The search box has the keyboard
+	input     string
+	matches   []string
+	match     int
+
+	status   string
+	watching string
+
+	mu      sync.Mutex
+	pending map[string]bool // Keys changed since the last refresh
+}
+
+// Run shows the keys under prefix until the user quits or ctx is done.
+// timeout bounds each request made in response to a key press.
+func Run(ctx context.Context, cli *clientv3.Client, prefix string, timeout time.Duration) error {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return fmt.Errorf("failed to open the terminal: %w", err)
+	}
+	if err := screen.Init(); err != nil {
+		return fmt.Errorf("failed to open the terminal: %w", err)
+	}
+	defer screen.Fini()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	b := &browser{
+		ctx:     ctx,
+		cli:     cli,
+		timeout: timeout,
+		screen:  screen,
+		root:    &node{path: prefix, dir: true, open: true, depth: -1},
+		pending: make(map[string]bool),
+	}
+	if err := b.load(b.root); err != nil {
+		return fmt.Errorf("failed to list '%s': %w", prefix, err)
+	}
+	b.flatten()
+	b.showSelected()
+
+	b.watching = "watching"
+	go func() {
+		err := walker.Watch(ctx, cli, prefix, walker.WatchOptions{}, func(ev *clientv3.Event) error {
+			b.changed(string(ev.Kv.Key))
+			return nil
+		})
+		screen.PostEvent(tcell.NewEventInterrupt(watchEndEvent{err}))
+	}()
+	go func() {
+		<-ctx.Done()
+		screen.PostEvent(tcell.NewEventInterrupt(nil))
+	}()
+
+	for ctx.Err() == nil {
+		b.draw()
+		switch ev := screen.PollEvent().(type) {
+		case nil:
+			return nil
+		case *tcell.EventResize:
+			screen.Sync()
+		case *tcell.EventKey:
+			if b.key(ev) {
+				return nil
+			}
+		case *tcell.EventInterrupt:
+			switch data := ev.Data().(type) {
+			case refreshEvent:
+				b.refresh()
+			case watchEndEvent:
+				if data.err != nil && !errors.Is(data.err, context.Canceled) {
+					b.watching = "not watching: " + data.err.Error()
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// request bounds a request made by the event loop.
+func (b *browser) request() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(b.ctx, b.timeout)
+}
+
+// load lists a directory's children, keeping the state of the ones it had
+// so that a refresh doesn't fold the tree up.
+func (b *browser) load(n *node) error {
+	ctx, cancel := b.request()
+	entries, err := walker.ListDir(ctx, b.cli, n.path, "", maxEntries)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	old := make(map[string]*node, len(n.children))
+	for _, c := range n.children {
+		old[c.name] = c
+	}
+	n.children = n.children[:0]
+	for _, e := range entries {
+		c := old[e.Name]
+		if c == nil || c.dir != e.Dir {
+			c = &node{name: e.Name, path: n.path + e.Name, dir: e.Dir, depth: n.depth + 1, parent: n}
+			if e.Dir {
+				c.path += "/"
+			}
+		}
+		n.children = append(n.children, c)
+	}
+	n.loaded = true
+	n.truncated = len(entries) == maxEntries
+	return nil
+}
+
+// flatten rebuilds the visible rows, keeping the selection on the same path
+// when it is still there.
+func (b *browser) flatten() {
+	selected := ""
+	if b.sel < len(b.rows) {
+		selected = b.rows[b.sel].path
+	}
+	b.rows = b.rows[:0]
+	var walk func(n *node)
+	walk = func(n *node) {
+		for _, c := range n.children {
+			b.rows = append(b.rows, c)
+			if c.open {
+				walk(c)
+			}
+		}
+	}
+	walk(b.root)
+
+	b.sel = min(b.sel, max(len(b.rows)-1, 0))
+	for i, r := range b.rows {
+		if r.path == selected {
+			b.sel = i
+			break
+		}
+	}
+}
+
+func (b *browser) selected() *node {
+	if b.sel < len(b.rows) {
+		return b.rows[b.sel]
+	}
+	return nil
+}
+
+// showSelected fetches the selected key for the value pane.
+func (b *browser) showSelected() {
+	b.value, b.valueLines, b.valueTop = nil, nil, 0
+	n := b.selected()
+	switch {
+	case n == nil:
+		b.valueLines = []string{"No keys under " + b.root.path}
+		return
+	case n.dir:
+		state := "Enter to open"
+		if n.open {
+			state = fmt.Sprintf("%d names", len(n.children))
+		}
+		b.valueLines = []string{n.path, "", "Directory, " + state}
+		return
+	}
+
+	ctx, cancel := b.request()
+	resp, err := b.cli.Get(ctx, n.path)
+	cancel()
+	switch {
+	case err != nil:
+		b.valueLines = []string{n.path, "", "Error: " + err.Error()}
+	case len(resp.Kvs) == 0:
+		b.valueLines = []string{n.path, "", "Deleted"}
+	default:
+		b.value = resp.Kvs[0]
+		b.valueLines = valueLines(b.value)
+	}
+}
+
+// key handles a key press and reports whether to quit.
+func (b *browser) key(ev *tcell.EventKey) bool {
+	if ev.Key() == tcell.KeyCtrlC {
+		return true
+	}
+	if b.searching {
+		b.searchKey(ev)
+		return false
+	}
+	if b.focusValue {
+		b.valueKey(ev)
+		return false
+	}
+
+	_, height := b.screen.Size()
+	page := max(height-3, 1)
+	moved := true
+	switch {
+	case ev.Key() == tcell.KeyUp || ev.Rune() == 'k':
+		b.sel--
+	case ev.Key() == tcell.KeyDown || ev.Rune() == 'j':
+		b.sel++
+	case ev.Key() == tcell.KeyPgUp:
+		b.sel -= page
+	case ev.Key() == tcell.KeyPgDn:
+		b.sel += page
+	case ev.Key() == tcell.KeyHome || ev.Rune() == 'g':
+		b.sel = 0
+	case ev.Key() == tcell.KeyEnd || ev.Rune() == 'G':
+		b.sel = len(b.rows) - 1
+	case ev.Key() == tcell.KeyRight || ev.Key() == tcell.KeyEnter || ev.Rune() == 'l':
+		moved = false
+		if n := b.selected(); n != nil && n.dir {
+			b.expand(n)
+		} else if n != nil {
+			b.focusValue = true
+		}
+	case ev.Key() == tcell.KeyLeft || ev.Rune() == 'h':
+		n := b.selected()
+		switch {
+		case n == nil:
+		case n.dir && n.open:
+			n.open = false
+			b.flatten()
+		case n.parent != b.root:
+			for i, r := range b.rows {
+				if r == n.parent {
+					b.sel = i
+				}
+			}
+		}
+	case ev.Key() == tcell.KeyTab:
+		moved = false
+		b.focusValue = b.value != nil
+	case ev.Rune() == '/':
+		moved = false
+		b.searching, b.input = true, ""
+	case ev.Rune() == 'n':
+		moved = false
+		b.nextMatch(1)
+	case ev.Rune() == 'N':
+		moved = false
+		b.nextMatch(-1)
+	case ev.Rune() == 'r':
+		moved = false
+		b.reloadAll()
+	case ev.Rune() == 'q' || ev.Key() == tcell.KeyEscape:
+		return true
+	default:
+		moved = false
+	}
+	if moved {
+		b.sel = min(max(b.sel, 0), max(len(b.rows)-1, 0))
+		b.showSelected()
+	}
+	return false
+}
+
+func (b *browser) valueKey(ev *tcell.EventKey) {
+	_, height := b.screen.Size()
+	page := max(height-3, 1)
+	switch {
+	case ev.Key() == tcell.KeyUp || ev.Rune() == 'k':
+		b.valueTop--
+	case ev.Key() == tcell.KeyDown || ev.Rune() == 'j':
+		b.valueTop++
+	case ev.Key() == tcell.KeyPgUp:
+		b.valueTop -= page
+	case ev.Key() == tcell.KeyPgDn || ev.Rune() == ' ':
+		b.valueTop += page
+	case ev.Key() == tcell.KeyTab || ev.Key() == tcell.KeyLeft || ev.Key() == tcell.KeyEscape || ev.Rune() == 'h' || ev.Rune() == 'q':
+		b.focusValue = false
+	}
+	b.valueTop = min(max(b.valueTop, 0), max(len(b.valueLines)-1, 0))
+}
+
+func (b *browser) searchKey(ev *tcell.EventKey) {
+	switch ev.Key() {
+	case tcell.KeyEscape:
+		b.searching = false
+	case tcell.KeyEnter:
+		b.searching = false
+		b.search(b.input)
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if r := []rune(b.input); len(r) > 0 {
+			b.input = string(r[:len(r)-1])
+		}
+	case tcell.KeyRune:
+		b.input += string(ev.Rune())
+	}
+}
+
+// expand opens a directory, loading it the first time.
+func (b *browser) expand(n *node) {
+	if !n.loaded {
+		if err := b.load(n); err != nil {
+			b.status = fmt.Sprintf("Failed to list '%s': %v", n.path, err)
+			return
+		}
+	}
+	n.open = true
+	b.flatten()
+	b.showSelected()
+}
+
+// search collects the keys under the root matching pattern, which is case
+// insensitive unless it has upper case letters, and jumps to the first one.
+func (b *browser) search(pattern string) {
+	if pattern == "" {
+		return
+	}
+	if strings.ToLower(pattern) == pattern {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		b.status = "Bad pattern: " + err.Error()
+		return
+	}
+
+	b.matches, b.match = nil, 0
+	errFull := errors.New("enough matches")
+	ctx, cancel := context.WithTimeout(b.ctx, 4*b.timeout)
+	_, err = walker.Grep(ctx, b.cli, b.root.path, re, walker.GrepKeys, func(m walker.GrepMatch) error {
+		b.matches = append(b.matches, m.Key)
+		if len(b.matches) == maxMatches {
+			return errFull
+		}
+		return nil
+	})
+	cancel()
+	if err != nil && err != errFull {
+		b.status = "Search failed: " + err.Error()
+		return
+	}
+	if len(b.matches) == 0 {
+		b.status = "No keys match " + b.input
+		return
+	}
+	b.nextMatch(0)
+}
+
+// nextMatch moves by step through the search matches and reveals the key.
+func (b *browser) nextMatch(step int) {
+	if len(b.matches) == 0 {
+		b.status = "No search, press / to search keys"
+		return
+	}
+	b.match = (b.match + step + len(b.matches)) % len(b.matches)
+	key := b.matches[b.match]
+	if !b.reveal(key) {
+		b.status = fmt.Sprintf("'%s' is not in the tree view", key)
+		return
+	}
+	more := ""
+	if len(b.matches) == maxMatches {
+		more = "+"
+	}
+	b.status = fmt.Sprintf("Match %d of %d%s for %s, n/N for the next", b.match+1, len(b.matches), more, b.input)
+}
+
+// reveal opens the directories down to key and selects it.
+func (b *browser) reveal(key string) bool {
+	n := b.root
+	for {
+		e, ok := walker.SplitKey(n.path, key)
+		if !ok {
+			return false
+		}
+		if !n.loaded {
+			if err := b.load(n); err != nil {
+				return false
+			}
+		}
+		n.open = true
+		var child *node
+		for _, c := range n.children {
+			if c.name == e.Name {
+				child = c
+			}
+		}
+		if child == nil {
+			return false
+		}
+		if !e.Dir {
+			b.flatten()
+			for i, r := range b.rows {
+				if r == child {
+					b.sel = i
+				}
+			}
+			b.showSelected()
+			return true
+		}
+		n = child
+	}
+}
+
+// changed is called by the watch for every event. Keys are collected and a
+// single refresh is posted for a burst of events.
+func (b *browser) changed(key string) {
+	b.mu.Lock()
+	first := len(b.pending) == 0
+	b.pending[key] = true
+	b.mu.Unlock()
+	if first {
+		b.postRefresh()
+	}
+}
+
+// postRefresh posts the refresh of the pending keys. No other refresh is
+// posted until they are handled, so when the event queue is full it tries
+// again shortly rather than leaving them pending for good.
+func (b *browser) postRefresh() {
+	err := b.screen.PostEvent(tcell.NewEventInterrupt(refreshEvent{}))
+	if err != nil && b.ctx.Err() == nil {
+		time.AfterFunc(refreshRetry, b.postRefresh)
+	}
+}
+
+// refresh reloads the loaded directories on the path to each changed key,
+// and the value pane if its key changed.
+func (b *browser) refresh() {
+	b.mu.Lock()
+	keys := b.pending
+	b.pending = make(map[string]bool)
+	b.mu.Unlock()
+
+	reload := make(map[*node]bool)
+	for key := range keys {
+		n := b.root
+		for n != nil && n.loaded {
+			reload[n] = true
+			e, ok := walker.SplitKey(n.path, key)
+			if !ok || !e.Dir {
+				break
+			}
+			var next *node
+			for _, c := range n.children {
+				if c.name == e.Name && c.dir {
+					next = c
+				}
+			}
+			n = next
+		}
+	}
+	for n := range reload {
+		if err := b.load(n); err != nil {
+			b.status = fmt.Sprintf("Failed to refresh '%s': %v", n.path, err)
+		}
+	}
+	b.flatten()
+	if n := b.selected(); n == nil || keys[n.path] || n.dir {
+		top := b.valueTop
+		b.showSelected()
+		b.valueTop = min(top, max(len(b.valueLines)-1, 0))
+	}
+	b.status = fmt.Sprintf("%d keys changed at %s", len(keys), time.Now().Format("15:04:05"))
+}
+
+// reloadAll reloads every loaded directory and the value pane.
+func (b *browser) reloadAll() {
+	var walk func(n *node)
+	walk = func(n *node) {
+		if !n.loaded {
+			return
+		}
+		if err := b.load(n); err != nil {
+			b.status = fmt.Sprintf("Failed to refresh '%s': %v", n.path, err)
+			return
+		}
+		for _, c := range n.children {
+			walk(c)
+		}
+	}
+	walk(b.root)
+	b.flatten()
+	b.showSelected()
+	b.status = "Reloaded at " + time.Now().Format("15:04:05")
+}
@@ -0,0 +1,70 @@
+package walker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Alarm is an alarm raised on a member.
+type Alarm struct {
+	MemberID uint64 `json:"member_id"`
+	Alarm    string `json:"alarm"` // NOSPACE or CORRUPT
+}
+
+// ErrNoSpace is returned by CheckNoSpace when the cluster refuses writes.
+var ErrNoSpace = errors.New("etcd is out of space")
+
+// Alarms lists the active alarms.
+func Alarms(ctx context.Context, cli *clientv3.Client) ([]Alarm, error) {
+	resp, err := cli.AlarmList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return alarms(resp.Alarms), nil
+}
+
+// DisarmAlarms disarms every active alarm and returns the ones it disarmed.
+func DisarmAlarms(ctx context.Context, cli *clientv3.Client) ([]Alarm, error) {
+	// An empty alarm member asks the client to disarm all of them
+	resp, err := cli.AlarmDisarm(ctx, &clientv3.AlarmMember{})
+	if err != nil {
+		return nil, err
+	}
+	return alarms(resp.Alarms), nil
+}
+
+// CheckNoSpace returns an error wrapping ErrNoSpace, with what to do about
+// it, when a NOSPACE alarm is raised: the database reached its quota and the
+// cluster rejects every write until space is freed and the alarm disarmed.
+// Failing to list alarms is not an error, since the write itself will then
+// report what is wrong.
+func CheckNoSpace(ctx context.Context, cli *clientv3.Client) error {
+	list, err := Alarms(ctx, cli)
+	if err != nil {
+		return nil
+	}
+	var members []string
+	for _, a := range list {
+		if a.Alarm == etcdserverpb.AlarmType_NOSPACE.String() {
+			members = append(members, fmt.Sprintf("%x", a.MemberID))
+		}
+	}
+	if len(members) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: NOSPACE alarm on member %s, the database reached its quota and only reads and deletes are accepted; "+
+		"free space with 'etcd-walker compact' and 'etcd-walker defrag', then run 'etcd-walker alarm disarm'", ErrNoSpace, strings.Join(members, ", "))
+}
+
+func alarms(members []*etcdserverpb.AlarmMember) []Alarm {
+	list := make([]Alarm, 0, len(members))
+	for _, a := range members {
+		list = append(list, Alarm{MemberID: a.MemberID, Alarm: a.Alarm.String()})
+	}
+	return list
+}
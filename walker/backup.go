@@ -0,0 +1,116 @@
+package walker
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// BackupEntry is one line of a backup file. Values are base64 in the JSON so
+// that binary values survive the round trip.
+type BackupEntry struct {
+	Key         string `json:"key"`
+	Value       []byte `json:"value"`
+	ModRevision int64  `json:"mod_revision"`
+}
+
+// Backup writes keys to a JSON Lines file that Restore can load.
+type Backup struct {
+	f   *os.File
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+// CreateBackup creates a backup file, refusing to replace an existing one.
+func CreateBackup(path string) (*Backup, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup: %w", err)
+	}
+	buf := bufio.NewWriter(f)
+	return &Backup{f: f, buf: buf, enc: json.NewEncoder(buf)}, nil
+}
+
+// Add appends a key to the backup.
+func (b *Backup) Add(kv *mvccpb.KeyValue) error {
+	return b.enc.Encode(BackupEntry{Key: string(kv.Key), Value: kv.Value, ModRevision: kv.ModRevision})
+}
+
+// Close flushes the backup to stable storage, so that it can be relied on
+// before anything is deleted.
+func (b *Backup) Close() error {
+	err := b.buf.Flush()
+	if err == nil {
+		err = b.f.Sync()
+	}
+	if cerr := b.f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	return nil
+}
+
+// RestoreReport summarises a Restore.
+type RestoreReport struct {
+	Restored int `json:"restored"`
+	Skipped  int `json:"skipped"` // Already present and not overwritten
+}
+
+// Restore puts every key of a backup file back, in transactions of up to
+// DefaultTxnOps keys. Keys that exist are left alone unless overwrite is set.
+// Leases are not restored, since the original ones have usually expired.
+func Restore(ctx context.Context, cli *clientv3.Client, path string, overwrite bool) (*RestoreReport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer f.Close()
+
+	report := &RestoreReport{}
+	var ops []clientv3.Op
+	flush := func() error {
+		if len(ops) == 0 {
+			return nil
+		}
+		resp, err := cli.Txn(ctx).Then(ops...).Commit()
+		if err != nil {
+			return err
+		}
+		for _, r := range resp.Responses {
+			if t := r.GetResponseTxn(); t != nil && !t.Succeeded {
+				report.Skipped++
+			} else {
+				report.Restored++
+			}
+		}
+		ops = ops[:0]
+		return nil
+	}
+
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for line := 1; dec.More(); line++ {
+		var e BackupEntry
+		if err := dec.Decode(&e); err != nil {
+			return report, fmt.Errorf("backup entry %d: %w", line, err)
+		}
+
+		put := clientv3.OpPut(e.Key, string(e.Value))
+		if !overwrite {
+			// Each key gets its own guard so one existing key doesn't fail the chunk
+			put = clientv3.OpTxn([]clientv3.Cmp{clientv3.Compare(clientv3.CreateRevision(e.Key), "=", 0)}, []clientv3.Op{put}, nil)
+		}
+		if ops = append(ops, put); len(ops) == DefaultTxnOps {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+	return report, flush()
+}
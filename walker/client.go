@@ -0,0 +1,24 @@
+package walker
+
+import (
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+// DefaultDialTimeout is used by Connect when no dial timeout is given.
+const DefaultDialTimeout = 5 * time.Second
+
+// Connect opens a client to the given endpoints. The client's own logging is
+// turned off; errors reach the caller through the returned values.
+func Connect(endpoints []string, dialTimeout time.Duration) (*clientv3.Client, error) {
+	if dialTimeout <= 0 {
+		dialTimeout = DefaultDialTimeout
+	}
+	return clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: dialTimeout,
+		Logger:      zap.NewNop(),
+	})
+}
@@ -0,0 +1,88 @@
+package walker
+
+import (
+	"bytes"
+	"context"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+)
+
+// Change kinds reported by Compare.
+const (
+	Added    = "added"
+	Removed  = "removed"
+	Modified = "modified"
+)
+
+// Change is a key that differs between the two sides of a Compare. Old is
+// nil for Added keys and New is nil for Removed ones.
+type Change struct {
+	Key  string
+	Kind string
+	Old  *mvccpb.KeyValue
+	New  *mvccpb.KeyValue
+}
+
+// CompareSummary counts the outcome of a Compare.
+type CompareSummary struct {
+	Added     int `json:"added"`
+	Removed   int `json:"removed"`
+	Modified  int `json:"modified"`
+	Unchanged int `json:"unchanged"`
+}
+
+// Compare merges two cursors in key order and calls fn for every key whose
+// value differs, is only in older (removed) or only in newer (added). Only a
+// page of each side is held in memory at a time. Both cursors must yield
+// keys in the same order, which they do when they cover the same prefix.
+func Compare(ctx context.Context, older, newer *Cursor, fn func(Change) error) (CompareSummary, error) {
+	var sum CompareSummary
+	o, err := older.Next(ctx)
+	if err != nil {
+		return sum, err
+	}
+	n, err := newer.Next(ctx)
+	if err != nil {
+		return sum, err
+	}
+
+	for o != nil || n != nil {
+		var ch *Change
+		advanceOld, advanceNew := false, false
+		switch {
+		case n == nil || (o != nil && bytes.Compare(o.Key, n.Key) < 0):
+			ch = &Change{Key: string(o.Key), Kind: Removed, Old: o}
+			sum.Removed++
+			advanceOld = true
+		case o == nil || bytes.Compare(o.Key, n.Key) > 0:
+			ch = &Change{Key: string(n.Key), Kind: Added, New: n}
+			sum.Added++
+			advanceNew = true
+		default:
+			if !bytes.Equal(o.Value, n.Value) {
+				ch = &Change{Key: string(o.Key), Kind: Modified, Old: o, New: n}
+				sum.Modified++
+			} else {
+				sum.Unchanged++
+			}
+			advanceOld, advanceNew = true, true
+		}
+
+		if ch != nil {
+			if err := fn(*ch); err != nil {
+				return sum, err
+			}
+		}
+		if advanceOld {
+			if o, err = older.Next(ctx); err != nil {
+				return sum, err
+			}
+		}
+		if advanceNew {
+			if n, err = newer.Next(ctx); err != nil {
+				return sum, err
+			}
+		}
+	}
+	return sum, nil
+}
@@ -0,0 +1,115 @@
+package walker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// DefaultTxnOps matches the server's default --max-txn-ops.
+const DefaultTxnOps = 128
+
+// CopyOptions controls how Copy writes to the destination.
+type CopyOptions struct {
+	ChunkSize   int  // Puts per transaction, DefaultTxnOps when zero
+	NoOverwrite bool // Skip keys that already exist at the destination
+}
+
+// CopyReport summarises a Copy run.
+type CopyReport struct {
+	Revision int64    `json:"revision"` // Source revision the keys were read at
+	Copied   int      `json:"copied"`
+	Skipped  int      `json:"skipped"` // Identical at the destination, or existing with NoOverwrite
+	Failed   int      `json:"failed"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// Copy writes every key under srcPrefix on src to dstPrefix on dst, keeping the
+// part of the key after the prefix. src and dst may be the same client. The
+// source is read at a single revision and written in transactions of up to
+// ChunkSize puts; a failed transaction is recorded and the copy carries on.
+// Leases are not copied.
+func Copy(ctx context.Context, src, dst *clientv3.Client, srcPrefix, dstPrefix string, opts CopyOptions) (*CopyReport, error) {
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultTxnOps
+	}
+
+	report := &CopyReport{}
+	var chunk []*mvccpb.KeyValue
+	flush := func() error {
+		err := copyChunk(ctx, dst, chunk, srcPrefix, dstPrefix, opts, report)
+		chunk = chunk[:0]
+		return err
+	}
+
+	rev, err := Scan(ctx, src, srcPrefix, ScanOptions{}, func(kv *mvccpb.KeyValue) error {
+		chunk = append(chunk, kv)
+		if len(chunk) < chunkSize {
+			return nil
+		}
+		return flush()
+	})
+	report.Revision = rev
+	if err != nil {
+		return report, err
+	}
+	if len(chunk) > 0 {
+		if err := flush(); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// copyChunk writes one chunk of source keys. Only a cancelled context is
+// returned as an error; transaction failures are counted in the report.
+func copyChunk(ctx context.Context, dst *clientv3.Client, chunk []*mvccpb.KeyValue, srcPrefix, dstPrefix string, opts CopyOptions, report *CopyReport) error {
+	dstKey := func(kv *mvccpb.KeyValue) string {
+		return dstPrefix + strings.TrimPrefix(string(kv.Key), srcPrefix)
+	}
+
+	// Look at what is already there so re-runs don't rewrite identical values
+	gets := make([]clientv3.Op, 0, len(chunk))
+	for _, kv := range chunk {
+		gets = append(gets, clientv3.OpGet(dstKey(kv)))
+	}
+	existing, err := dst.Txn(ctx).Then(gets...).Commit()
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		report.Failed += len(chunk)
+		report.Errors = append(report.Errors, fmt.Sprintf("reading destination %s..%s: %v", dstKey(chunk[0]), dstKey(chunk[len(chunk)-1]), err))
+		return nil
+	}
+
+	var puts []clientv3.Op
+	for i, kv := range chunk {
+		if cur := existing.Responses[i].GetResponseRange().Kvs; len(cur) > 0 {
+			if opts.NoOverwrite || bytes.Equal(cur[0].Value, kv.Value) {
+				report.Skipped++
+				continue
+			}
+		}
+		puts = append(puts, clientv3.OpPut(dstKey(kv), string(kv.Value)))
+	}
+	if len(puts) == 0 {
+		return nil
+	}
+
+	if _, err := dst.Txn(ctx).Then(puts...).Commit(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		report.Failed += len(puts)
+		report.Errors = append(report.Errors, fmt.Sprintf("writing %s..%s: %v", dstKey(chunk[0]), dstKey(chunk[len(chunk)-1]), err))
+		return nil
+	}
+	report.Copied += len(puts)
+	return nil
+}
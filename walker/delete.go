@@ -0,0 +1,111 @@
+package walker
+
+import (
+	"context"
+	"fmt"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// DeletePlan describes what Delete would remove.
+type DeletePlan struct {
+	Revision int64    `json:"revision"` // Revision the keys were read at
+	Keys     int      `json:"keys"`
+	Bytes    int64    `json:"bytes"` // Key and value bytes
+	Sample   []string `json:"sample"`
+}
+
+// PlanDelete reads key, or with prefix every key under it, at one revision
+// and sums up what deleting them would remove, keeping the first sample keys.
+// fn, when not nil, is called for every key; rm writes its backup from it.
+func PlanDelete(ctx context.Context, cli *clientv3.Client, key string, prefix bool, sample int, fn func(kv *mvccpb.KeyValue) error) (*DeletePlan, error) {
+	plan := &DeletePlan{Sample: []string{}}
+	rev, err := eachKey(ctx, cli, key, prefix, 0, func(kv *mvccpb.KeyValue) error {
+		plan.Keys++
+		plan.Bytes += int64(len(kv.Key) + len(kv.Value))
+		if len(plan.Sample) < sample {
+			plan.Sample = append(plan.Sample, string(kv.Key))
+		}
+		if fn != nil {
+			return fn(kv)
+		}
+		return nil
+	})
+	plan.Revision = rev
+	return plan, err
+}
+
+// Delete removes the keys of a plan: it reads them again at the plan's
+// revision and deletes them in transactions of DefaultTxnOps keys, each
+// guarded so that it only applies if none of its keys changed since. Keys
+// created under the prefix after that revision are left alone, so nothing is
+// deleted that a backup taken from PlanDelete doesn't hold. fn is called with
+// the previous value the server reports for every deleted key. Delete stops
+// at the first chunk that fails and returns how many keys were deleted.
+func Delete(ctx context.Context, cli *clientv3.Client, key string, prefix bool, rev int64, fn func(prev *mvccpb.KeyValue) error) (int, error) {
+	deleted := 0
+	var chunk []*mvccpb.KeyValue
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		var cmps []clientv3.Cmp
+		var ops []clientv3.Op
+		for _, kv := range chunk {
+			cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(string(kv.Key)), "=", kv.ModRevision))
+			ops = append(ops, clientv3.OpDelete(string(kv.Key), clientv3.WithPrevKV()))
+		}
+		resp, err := cli.Txn(ctx).If(cmps...).Then(ops...).Commit()
+		if err != nil {
+			return fmt.Errorf("deleting '%s'..'%s': %w", chunk[0].Key, chunk[len(chunk)-1].Key, err)
+		}
+		if !resp.Succeeded {
+			return fmt.Errorf("a key in '%s'..'%s' changed after revision %d, not deleting them", chunk[0].Key, chunk[len(chunk)-1].Key, rev)
+		}
+		for _, r := range resp.Responses {
+			for _, prev := range r.GetResponseDeleteRange().PrevKvs {
+				deleted++
+				if err := fn(prev); err != nil {
+					return err
+				}
+			}
+		}
+		chunk = chunk[:0]
+		return nil
+	}
+
+	_, err := eachKey(ctx, cli, key, prefix, rev, func(kv *mvccpb.KeyValue) error {
+		if chunk = append(chunk, kv); len(chunk) == DefaultTxnOps {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	return deleted, err
+}
+
+// eachKey calls fn for key, or every key under it, read at rev (the current
+// revision when zero), and returns the revision read at.
+func eachKey(ctx context.Context, cli *clientv3.Client, key string, prefix bool, rev int64, fn func(kv *mvccpb.KeyValue) error) (int64, error) {
+	if prefix {
+		return Scan(ctx, cli, key, ScanOptions{Revision: rev}, fn)
+	}
+
+	var getOpts []clientv3.OpOption
+	if rev > 0 {
+		getOpts = append(getOpts, clientv3.WithRev(rev))
+	}
+	resp, err := cli.Get(ctx, key, getOpts...)
+	if err != nil {
+		return rev, err
+	}
+	for _, kv := range resp.Kvs {
+		if err := fn(kv); err != nil {
+			return resp.Header.Revision, err
+		}
+	}
+	return resp.Header.Revision, nil
+}
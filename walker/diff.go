@@ -0,0 +1,200 @@
+package walker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// DiffContext is the number of unchanged lines shown around each change.
+const DiffContext = 3
+
+// maxDiffCells bounds the LCS table; larger changes are shown as a whole
+// block removed and added rather than exhausting memory.
+const maxDiffCells = 4 << 20
+
+// ValueLines splits a value into lines for diffing. JSON is re-indented so
+// that single-line documents diff field by field, and binary values are
+// shown as one line of printable characters.
+func ValueLines(v []byte) []string {
+	if len(v) == 0 {
+		return nil
+	}
+	if json.Valid(v) {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, v, "", "  "); err == nil {
+			v = buf.Bytes()
+		}
+	}
+	if !IsText(v) {
+		return []string{printable(v)}
+	}
+	return strings.Split(strings.TrimSuffix(string(v), "\n"), "\n")
+}
+
+// IsText reports whether v is UTF-8 without control characters other than
+// tabs and line breaks.
+func IsText(v []byte) bool {
+	if !utf8.Valid(v) {
+		return false
+	}
+	for _, c := range v {
+		if (c < 0x20 && c != '\t' && c != '\n' && c != '\r') || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// DiffValues returns a unified diff from old to new, or "" if they are equal.
+func DiffValues(old, new []byte) string {
+	if bytes.Equal(old, new) {
+		return ""
+	}
+	return Unified(DiffLines(ValueLines(old), ValueLines(new)), DiffContext)
+}
+
+// DiffLine is a line of a diff. Op is ' ' for context, '-' or '+'.
+type DiffLine struct {
+	Op   byte
+	Text string
+}
+
+// DiffLines computes a line diff from a to b.
+func DiffLines(a, b []string) []DiffLine {
+	// Common prefix and suffix are cheap and usually most of a value
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	var out []DiffLine
+	for _, l := range a[:pre] {
+		out = append(out, DiffLine{' ', l})
+	}
+	out = append(out, diffMiddle(a[pre:len(a)-suf], b[pre:len(b)-suf])...)
+	for _, l := range a[len(a)-suf:] {
+		out = append(out, DiffLine{' ', l})
+	}
+	return out
+}
+
+// diffMiddle diffs the part of two values between their common ends using a
+// longest common subsequence table.
+func diffMiddle(a, b []string) []DiffLine {
+	var out []DiffLine
+	if (len(a)+1)*(len(b)+1) > maxDiffCells {
+		for _, l := range a {
+			out = append(out, DiffLine{'-', l})
+		}
+		for _, l := range b {
+			out = append(out, DiffLine{'+', l})
+		}
+		return out
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, DiffLine{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, DiffLine{'-', a[i]})
+			i++
+		default:
+			out = append(out, DiffLine{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out = append(out, DiffLine{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		out = append(out, DiffLine{'+', b[j]})
+	}
+	return out
+}
+
+// Unified formats a diff as unified diff hunks with context lines around
+// each change.
+func Unified(lines []DiffLine, context int) string {
+	var sb strings.Builder
+	for start := 0; start < len(lines); {
+		// Find the next change and the end of its hunk
+		first := start
+		for first < len(lines) && lines[first].Op == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+		end := first
+		for last := first; last < len(lines); last++ {
+			if lines[last].Op != ' ' {
+				end = last + 1
+			} else if last-end >= 2*context {
+				break
+			}
+		}
+		from, to := max(first-context, start), min(end+context, len(lines))
+
+		// Line numbers of the hunk in the old and new value, 1-based
+		oldLine, newLine := 1, 1
+		for _, l := range lines[:from] {
+			if l.Op != '+' {
+				oldLine++
+			}
+			if l.Op != '-' {
+				newLine++
+			}
+		}
+		oldCount, newCount := 0, 0
+		for _, l := range lines[from:to] {
+			if l.Op != '+' {
+				oldCount++
+			}
+			if l.Op != '-' {
+				newCount++
+			}
+		}
+
+		// An empty side is numbered by the line before it, as diff(1) does
+		if oldCount == 0 {
+			oldLine--
+		}
+		if newCount == 0 {
+			newLine--
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", oldLine, oldCount, newLine, newCount)
+		for _, l := range lines[from:to] {
+			sb.WriteByte(l.Op)
+			sb.WriteString(l.Text)
+			sb.WriteByte('\n')
+		}
+		start = to
+	}
+	return sb.String()
+}
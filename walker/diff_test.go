@@ -0,0 +1,146 @@
+package walker
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		want string // One line per DiffLine, op then text
+	}{
+		{"equal", []string{"a", "b"}, []string{"a", "b"}, " a  b"},
+		{"both empty", nil, nil, ""},
+		{"from empty", nil, []string{"a", "b"}, "+a +b"},
+		{"to empty", []string{"a", "b"}, nil, "-a -b"},
+		{"change in the middle", []string{"a", "b", "c"}, []string{"a", "x", "c"}, " a -b +x  c"},
+		{"insert", []string{"a", "c"}, []string{"a", "b", "c"}, " a +b  c"},
+		{"delete", []string{"a", "b", "c"}, []string{"a", "c"}, " a -b  c"},
+		{"keeps the longest common run", []string{"a", "b", "c", "d"}, []string{"b", "c", "d", "a"}, "-a  b  c  d +a"},
+		{"removals before additions", []string{"x", "y"}, []string{"p", "q"}, "-x -y +p +q"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, l := range DiffLines(tt.a, tt.b) {
+				got = append(got, string(l.Op)+l.Text)
+			}
+			if s := strings.Join(got, " "); s != tt.want {
+				t.Errorf("DiffLines(%q, %q) = %q, want %q", tt.a, tt.b, s, tt.want)
+			}
+		})
+	}
+}
+
+// numbered returns the lines "1" to "n".
+func numbered(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprint(i + 1)
+	}
+	return lines
+}
+
+// replaced returns lines with the given 1-based line numbers suffixed by "x".
+func replaced(lines []string, at ...int) []string {
+	out := append([]string(nil), lines...)
+	for _, n := range at {
+		out[n-1] += "x"
+	}
+	return out
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		want string
+	}{
+		{"equal", numbered(3), numbered(3), ""},
+		{
+			"context is clipped at the ends",
+			numbered(3), replaced(numbered(3), 2),
+			"@@ -1,3 +1,3 @@\n 1\n-2\n+2x\n 3\n",
+		},
+		{
+			"context around a change",
+			numbered(10), replaced(numbered(10), 5),
+			"@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+5x\n 6\n 7\n 8\n",
+		},
+		{
+			"changes twice the context apart share a hunk",
+			numbered(12), replaced(numbered(12), 2, 9),
+			"@@ -1,12 +1,12 @@\n 1\n-2\n+2x\n 3\n 4\n 5\n 6\n 7\n 8\n-9\n+9x\n 10\n 11\n 12\n",
+		},
+		{
+			"changes further apart get their own hunks",
+			numbered(13), replaced(numbered(13), 2, 10),
+			"@@ -1,5 +1,5 @@\n 1\n-2\n+2x\n 3\n 4\n 5\n" +
+				"@@ -7,7 +7,7 @@\n 7\n 8\n 9\n-10\n+10x\n 11\n 12\n 13\n",
+		},
+		{
+			"empty to value",
+			nil, []string{"a", "b"},
+			"@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			"value to empty",
+			[]string{"a", "b"}, nil,
+			"@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			"lines appended",
+			numbered(2), numbered(3),
+			"@@ -1,2 +1,3 @@\n 1\n 2\n+3\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified(DiffLines(tt.a, tt.b), DiffContext); got != tt.want {
+				t.Errorf("Unified() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{"equal", "a", "a", ""},
+		{"created", "", "v\n", "@@ -0,0 +1,1 @@\n+v\n"},
+		{"emptied", "v", "", "@@ -1,1 +0,0 @@\n-v\n"},
+		{"json is diffed field by field", `{"a":1,"b":2}`, `{"a":1,"b":3}`,
+			"@@ -1,4 +1,4 @@\n {\n   \"a\": 1,\n-  \"b\": 2\n+  \"b\": 3\n }\n"},
+		{"binary is one printable line", "\x00a", "\x00b", "@@ -1,1 +1,1 @@\n-.a\n+.b\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DiffValues([]byte(tt.old), []byte(tt.new)); got != tt.want {
+				t.Errorf("DiffValues(%q, %q) =\n%s\nwant\n%s", tt.old, tt.new, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValueLines(t *testing.T) {
+	tests := []struct {
+		v    string
+		want []string
+	}{
+		{"", nil},
+		{"a\nb\n", []string{"a", "b"}},
+		{`[1]`, []string{"[", "  1", "]"}},
+	}
+	for _, tt := range tests {
+		if got := ValueLines([]byte(tt.v)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ValueLines(%q) = %q, want %q", tt.v, got, tt.want)
+		}
+	}
+}
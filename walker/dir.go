@@ -0,0 +1,111 @@
+package walker
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// DirEntry is a name in the directory view of the keyspace that the FUSE
+// mount, the shell and the UI show: keys are split on "/" and every segment
+// followed by more segments is a directory.
+type DirEntry struct {
+	Name string
+	Dir  bool
+}
+
+// SplitKey returns the entry key shows up as directly under dir, which ends
+// in "/". ok is false for keys that show up as nothing there: dir itself, keys
+// not under it, and keys with an empty segment right after it.
+func SplitKey(dir, key string) (entry DirEntry, ok bool) {
+	rel, found := strings.CutPrefix(key, dir)
+	if !found || rel == "" {
+		return DirEntry{}, false
+	}
+	name, rest, _ := strings.Cut(rel, "/")
+	if name == "" {
+		return DirEntry{}, false
+	}
+	return DirEntry{Name: name, Dir: rest != ""}, true
+}
+
+// AddEntry adds e to a listing. A name that is both a key and a directory
+// lists as the directory, so that its children stay reachable.
+func AddEntry(entries map[string]DirEntry, e DirEntry) {
+	if old, ok := entries[e.Name]; !ok || !old.Dir {
+		entries[e.Name] = e
+	}
+}
+
+// ListDir returns the entries directly under dir whose names start with
+// name, sorted, reading keys only and skipping over the keys of each
+// subdirectory rather than fetching them all. It stops after limit entries
+// when limit is positive.
+func ListDir(ctx context.Context, cli *clientv3.Client, dir, name string, limit int) ([]DirEntry, error) {
+	entries := make(map[string]DirEntry)
+	err := walkDir(ctx, cli, dir, name, []clientv3.OpOption{clientv3.WithKeysOnly()}, func(_ *mvccpb.KeyValue, e DirEntry) bool {
+		AddEntry(entries, e)
+		return limit <= 0 || len(entries) < limit
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]DirEntry, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+// ReadDir calls fn, in key order, with the keys read to list dir, values
+// included, and the entry each shows up as. The keys of a subdirectory are
+// skipped over as in ListDir, so fn sees some of them but not all.
+func ReadDir(ctx context.Context, cli *clientv3.Client, dir string, fn func(kv *mvccpb.KeyValue, e DirEntry)) error {
+	return walkDir(ctx, cli, dir, "", nil, func(kv *mvccpb.KeyValue, e DirEntry) bool {
+		fn(kv, e)
+		return true
+	})
+}
+
+// walkDir reads the keys under dir + name a page at a time, jumping past the
+// rest of a subdirectory at the end of each page, and calls fn with the keys
+// that show up in dir. It stops after the page in which fn returns false.
+func walkDir(ctx context.Context, cli *clientv3.Client, dir, name string, opts []clientv3.OpOption, fn func(kv *mvccpb.KeyValue, e DirEntry) bool) error {
+	key, end := dir+name, clientv3.GetPrefixRangeEnd(dir+name)
+	if key == "" {
+		key = "\x00" // The top of the keyspace
+	}
+	opts = append(opts, clientv3.WithRange(end), clientv3.WithLimit(DefaultPageSize))
+	for more := true; more; {
+		resp, err := cli.Get(ctx, key, opts...)
+		if err != nil {
+			return err
+		}
+		for _, kv := range resp.Kvs {
+			if e, ok := SplitKey(dir, string(kv.Key)); ok && !fn(kv, e) {
+				more = false
+			}
+		}
+		if !resp.More || len(resp.Kvs) == 0 {
+			break
+		}
+		// Continue after the last key, or past the whole directory it is in,
+		// including the keys with an empty segment that list as nothing
+		last := string(resp.Kvs[len(resp.Kvs)-1].Key)
+		key = last + "\x00"
+		if rel, ok := strings.CutPrefix(last, dir); ok {
+			if first, rest, sub := strings.Cut(rel, "/"); sub && (rest != "" || first == "") {
+				key = clientv3.GetPrefixRangeEnd(dir + first + "/")
+			}
+		}
+	}
+	return nil
+}
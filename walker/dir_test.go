@@ -0,0 +1,113 @@
+package walker
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+
+	"github.com/CedricElie/etcd-walker/snapshot"
+	"github.com/CedricElie/etcd-walker/snapshot/snapshottest"
+)
+
+func TestSplitKey(t *testing.T) {
+	tests := []struct {
+		dir, key string
+		want     DirEntry
+		ok       bool
+	}{
+		{"/", "/a", DirEntry{"a", false}, true},
+		{"/", "/a/b", DirEntry{"a", true}, true},
+		{"/", "/a/", DirEntry{"a", false}, true},
+		{"/a/", "/a/b/c/d", DirEntry{"b", true}, true},
+		{"/a/", "/a/", DirEntry{}, false},
+		{"/a/", "/ab", DirEntry{}, false},
+		{"/a/", "/a//b", DirEntry{}, false},
+		{"", "plain", DirEntry{"plain", false}, true},
+		{"", "x/y", DirEntry{"x", true}, true},
+		{"", "/a", DirEntry{}, false},
+		{"/", "/é/ü", DirEntry{"é", true}, true},
+	}
+	for _, tt := range tests {
+		got, ok := SplitKey(tt.dir, tt.key)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("SplitKey(%q, %q) = %+v, %v, want %+v, %v", tt.dir, tt.key, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestListDir(t *testing.T) {
+	b := snapshottest.New(t)
+	for _, key := range []string{"/a", "/a/b", "/c", "/d/", "plain", "x/y", "/e//f"} {
+		b.Put(key, "v")
+	}
+	// More keys than a page, so that listing has to skip over them
+	for i := range DefaultPageSize + 10 {
+		b.Put(fmt.Sprintf("/big/%04d", i), "v")
+		b.Put(fmt.Sprintf("/e//%04d", i), "v")
+	}
+	db, err := snapshot.Open(b.Close())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	cli := db.Client(context.Background())
+
+	tests := []struct {
+		dir, name string
+		limit     int
+		want      []DirEntry
+	}{
+		{"/", "", 0, []DirEntry{{"a", true}, {"big", true}, {"c", false}, {"d", false}, {"e", true}}},
+		{"/", "b", 0, []DirEntry{{"big", true}}},
+		{"/", "", 2, []DirEntry{{"a", true}, {"big", true}}},
+		{"/e/", "", 0, nil},
+		{"/big/", "000", 0, []DirEntry{{"0000", false}, {"0001", false}, {"0002", false}, {"0003", false}, {"0004", false}, {"0005", false}, {"0006", false}, {"0007", false}, {"0008", false}, {"0009", false}}},
+		{"", "", 0, []DirEntry{{"plain", false}, {"x", true}}},
+		{"", "p", 0, []DirEntry{{"plain", false}}},
+		{"/nothing/", "", 0, nil},
+	}
+	for _, tt := range tests {
+		got, err := ListDir(context.Background(), cli, tt.dir, tt.name, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ListDir(%q, %q, %d) = %+v, want %+v", tt.dir, tt.name, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestReadDir(t *testing.T) {
+	b := snapshottest.New(t)
+	for _, key := range []string{"/a", "/a/", "/b/x", "/c", "/e//f"} {
+		b.Put(key, "value of "+key)
+	}
+	for i := range DefaultPageSize + 10 {
+		b.Put(fmt.Sprintf("/b/%04d", i), "v")
+	}
+	db, err := snapshot.Open(b.Close())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var got []string
+	err = ReadDir(context.Background(), db.Client(context.Background()), "/", func(kv *mvccpb.KeyValue, e DirEntry) {
+		if !e.Dir {
+			got = append(got, fmt.Sprintf("%s=%s", kv.Key, kv.Value))
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/a=value of /a", "/a/=value of /a/", "/c=value of /c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("files = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,34 @@
+package walker
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Value encodings reported by Encoding.
+const (
+	EncodingEmpty    = "empty"
+	EncodingJSON     = "json"
+	EncodingProtobuf = "protobuf" // Kubernetes objects stored with the "k8s\x00" magic
+	EncodingText     = "text"
+	EncodingBinary   = "binary"
+)
+
+// k8sMagic prefixes the protobuf encoding the Kubernetes API server uses.
+var k8sMagic = []byte("k8s\x00")
+
+// Encoding guesses how a value is encoded.
+func Encoding(v []byte) string {
+	switch {
+	case len(v) == 0:
+		return EncodingEmpty
+	case bytes.HasPrefix(v, k8sMagic):
+		return EncodingProtobuf
+	case json.Valid(v):
+		return EncodingJSON
+	case IsText(v):
+		return EncodingText
+	default:
+		return EncodingBinary
+	}
+}
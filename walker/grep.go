@@ -0,0 +1,88 @@
+package walker
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// SnippetContext is how many bytes of value are kept either side of a match.
+const SnippetContext = 40
+
+// GrepTarget selects what Grep matches the pattern against.
+type GrepTarget int
+
+const (
+	GrepBoth GrepTarget = iota
+	GrepKeys
+	GrepValues
+)
+
+// ParseGrepTarget parses "keys", "values" or "both".
+func ParseGrepTarget(s string) (GrepTarget, error) {
+	switch s {
+	case "both", "":
+		return GrepBoth, nil
+	case "keys":
+		return GrepKeys, nil
+	case "values":
+		return GrepValues, nil
+	}
+	return 0, fmt.Errorf("unknown match target %q, want keys, values or both", s)
+}
+
+// GrepMatch is a key whose key or value matched. When the value matched,
+// the snippet is split around the first match so callers can highlight it.
+type GrepMatch struct {
+	Key         string `json:"key"`
+	ModRevision int64  `json:"mod_revision"`
+	InKey       bool   `json:"in_key"`
+	InValue     bool   `json:"in_value"`
+	Before      string `json:"before,omitempty"`
+	Match       string `json:"match,omitempty"`
+	After       string `json:"after,omitempty"`
+}
+
+// Grep scans prefix page by page and calls fn for every key matching re.
+// It returns the revision the scan was pinned to.
+func Grep(ctx context.Context, cli *clientv3.Client, prefix string, re *regexp.Regexp, target GrepTarget, fn func(GrepMatch) error) (int64, error) {
+	return Scan(ctx, cli, prefix, ScanOptions{KeysOnly: target == GrepKeys}, func(kv *mvccpb.KeyValue) error {
+		m := GrepMatch{Key: string(kv.Key), ModRevision: kv.ModRevision}
+		if target != GrepValues {
+			m.InKey = re.Match(kv.Key)
+		}
+		if target != GrepKeys {
+			if loc := re.FindIndex(kv.Value); loc != nil {
+				m.InValue = true
+				m.Before = printable(kv.Value[max(0, loc[0]-SnippetContext):loc[0]])
+				m.Match = printable(kv.Value[loc[0]:loc[1]])
+				m.After = printable(kv.Value[loc[1]:min(len(kv.Value), loc[1]+SnippetContext)])
+			}
+		}
+		if !m.InKey && !m.InValue {
+			return nil
+		}
+		return fn(m)
+	})
+}
+
+// printable replaces control characters and invalid UTF-8 with '.', since
+// values are often binary (Kubernetes stores most objects as protobuf).
+func printable(b []byte) string {
+	var sb strings.Builder
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		if r == utf8.RuneError || !unicode.IsPrint(r) {
+			r = '.'
+		}
+		sb.WriteRune(r)
+		b = b[size:]
+	}
+	return sb.String()
+}
@@ -0,0 +1,67 @@
+package walker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// HistoryEnd says why History stopped walking back.
+type HistoryEnd struct {
+	Reason   string `json:"reason"`   // "created", "compacted" or "limit"
+	Revision int64  `json:"revision"` // Mod revision of the oldest version returned
+}
+
+// ErrNotFound is returned when a key does not exist at the requested revision.
+var ErrNotFound = errors.New("key not found")
+
+// History calls fn for each retained version of key, newest first, starting
+// from the version current at rev (or now when rev is zero). It walks back by
+// reading the key just before each version's mod revision, and stops at the
+// version that created the key, at the compaction horizon, or after limit
+// versions when limit is positive. A key that was deleted and created again
+// only has history back to its latest creation: nothing links it to the
+// versions before the deletion, which a History from an earlier rev reaches.
+func History(ctx context.Context, cli *clientv3.Client, key string, rev int64, limit int, fn func(kv *mvccpb.KeyValue) error) (HistoryEnd, error) {
+	var opts []clientv3.OpOption
+	if rev > 0 {
+		opts = append(opts, clientv3.WithRev(rev))
+	}
+	resp, err := cli.Get(ctx, key, opts...)
+	if err != nil {
+		return HistoryEnd{}, err
+	}
+	if len(resp.Kvs) == 0 {
+		return HistoryEnd{}, fmt.Errorf("%w at revision %d", ErrNotFound, resp.Header.Revision)
+	}
+
+	kv := resp.Kvs[0]
+	for n := 1; ; n++ {
+		if err := fn(kv); err != nil {
+			return HistoryEnd{}, err
+		}
+		if kv.Version == 1 {
+			return HistoryEnd{Reason: "created", Revision: kv.ModRevision}, nil
+		}
+		if limit > 0 && n >= limit {
+			return HistoryEnd{Reason: "limit", Revision: kv.ModRevision}, nil
+		}
+
+		resp, err := cli.Get(ctx, key, clientv3.WithRev(kv.ModRevision-1))
+		if errors.Is(err, rpctypes.ErrCompacted) {
+			return HistoryEnd{Reason: "compacted", Revision: kv.ModRevision}, nil
+		}
+		if err != nil {
+			return HistoryEnd{}, err
+		}
+		if len(resp.Kvs) == 0 {
+			// Version says there is an older one, so this shouldn't happen
+			return HistoryEnd{Reason: "created", Revision: kv.ModRevision}, nil
+		}
+		kv = resp.Kvs[0]
+	}
+}
@@ -0,0 +1,75 @@
+package walker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// LeaseID is a lease ID. It goes to JSON and YAML in hex, as etcdctl and the
+// text output print it, rather than as a decimal number.
+type LeaseID int64
+
+func (id LeaseID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.FormatInt(int64(id), 16))
+}
+
+// LeaseInfo describes a lease and the keys attached to it.
+type LeaseInfo struct {
+	ID         LeaseID  `json:"id"`
+	GrantedTTL int64    `json:"granted_ttl"` // Seconds
+	TTL        int64    `json:"ttl"`         // Seconds remaining
+	KeyCount   int      `json:"key_count"`
+	Keys       []string `json:"keys,omitempty"` // Only when asked for
+}
+
+// ErrLeaseNotFound is returned for leases that expired or never existed.
+var ErrLeaseNotFound = errors.New("lease not found")
+
+// Lease looks up one lease. The attached keys are always counted but only
+// returned when keys is set.
+func Lease(ctx context.Context, cli *clientv3.Client, id int64, keys bool) (LeaseInfo, error) {
+	resp, err := cli.TimeToLive(ctx, clientv3.LeaseID(id), clientv3.WithAttachedKeys())
+	if err != nil {
+		return LeaseInfo{}, err
+	}
+	// The server answers for unknown leases with a TTL of -1
+	if resp.TTL < 0 {
+		return LeaseInfo{}, fmt.Errorf("%w: %x", ErrLeaseNotFound, id)
+	}
+
+	info := LeaseInfo{ID: LeaseID(id), GrantedTTL: resp.GrantedTTL, TTL: resp.TTL, KeyCount: len(resp.Keys)}
+	if keys {
+		info.Keys = make([]string, len(resp.Keys))
+		for i, k := range resp.Keys {
+			info.Keys[i] = string(k)
+		}
+	}
+	return info, nil
+}
+
+// Leases calls fn for every lease, in the order the server lists them.
+// Leases that expire while they are being listed are skipped.
+func Leases(ctx context.Context, cli *clientv3.Client, keys bool, fn func(LeaseInfo) error) error {
+	resp, err := cli.Leases(ctx)
+	if err != nil {
+		return err
+	}
+	for _, l := range resp.Leases {
+		info, err := Lease(ctx, cli, int64(l.ID), keys)
+		if errors.Is(err, ErrLeaseNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("lease %x: %w", int64(l.ID), err)
+		}
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package walker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// DBSize reports the backend database size of the first endpoint the client
+// was configured with, and how much of it is in use.
+func DBSize(ctx context.Context, cli *clientv3.Client) (size, inUse int64, err error) {
+	endpoints := cli.Endpoints()
+	if len(endpoints) == 0 {
+		return 0, 0, fmt.Errorf("client has no endpoints")
+	}
+	resp, err := cli.Status(ctx, endpoints[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("status of %s: %w", endpoints[0], err)
+	}
+	return resp.DbSize, resp.DbSizeInUse, nil
+}
+
+// EndpointStatus is the Maintenance Status of one member, queried through its
+// first client URL.
+type EndpointStatus struct {
+	Endpoint    string        `json:"endpoint"`
+	MemberID    uint64        `json:"member_id"`
+	Name        string        `json:"name"`
+	Learner     bool          `json:"learner,omitempty"`
+	Version     string        `json:"version,omitempty"`
+	DBSize      int64         `json:"db_size,omitempty"`
+	DBSizeInUse int64         `json:"db_size_in_use,omitempty"`
+	Leader      uint64        `json:"leader,omitempty"`
+	IsLeader    bool          `json:"is_leader"`
+	RaftTerm    uint64        `json:"raft_term,omitempty"`
+	RaftIndex   uint64        `json:"raft_index,omitempty"`
+	Alarms      []string      `json:"alarms,omitempty"`
+	Latency     time.Duration `json:"latency_ns"`
+	Error       string        `json:"error,omitempty"` // Why the member could not be queried, or the errors it reports
+}
+
+// ClusterStatus is the state of every member, and what makes the cluster
+// unhealthy if anything. Alarms are kept apart from the other problems since
+// compacting and defragmenting are how a NOSPACE alarm gets resolved.
+type ClusterStatus struct {
+	Members  []EndpointStatus
+	Leader   uint64
+	Alarms   []string
+	Problems []string
+}
+
+// Healthy reports whether Status found no alarms or other problems.
+func (s *ClusterStatus) Healthy() bool {
+	return len(s.Alarms) == 0 && len(s.Problems) == 0
+}
+
+// Status lists the members and calls Maintenance Status on each of them
+// through its first client URL, or with endpoints on those endpoints instead,
+// for clusters whose advertised URLs are not reachable from here. Each call
+// is bounded by timeout. The cluster is unhealthy when a member cannot be
+// reached or reports errors, when there is no leader or members disagree on
+// it, or when an alarm is raised.
+func Status(ctx context.Context, cli *clientv3.Client, endpoints []string, timeout time.Duration) (*ClusterStatus, error) {
+	mctx, cancel := context.WithTimeout(ctx, timeout)
+	members, err := cli.MemberList(mctx)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list members: %w", err)
+	}
+
+	status := &ClusterStatus{}
+	alarms := make(map[uint64][]string)
+	actx, cancel := context.WithTimeout(ctx, timeout)
+	alarmResp, err := cli.AlarmList(actx)
+	cancel()
+	if err != nil {
+		status.Problems = append(status.Problems, fmt.Sprintf("failed to list alarms: %v", err))
+	} else {
+		for _, a := range alarmResp.Alarms {
+			alarms[a.MemberID] = append(alarms[a.MemberID], a.Alarm.String())
+			status.Alarms = append(status.Alarms, fmt.Sprintf("alarm %s on member %x", a.Alarm, a.MemberID))
+		}
+	}
+
+	var query []EndpointStatus
+	byID := make(map[uint64]*etcdserverpb.Member)
+	for _, m := range members.Members {
+		byID[m.ID] = m
+		if endpoints != nil {
+			continue
+		}
+		es := EndpointStatus{MemberID: m.ID, Name: m.Name, Learner: m.IsLearner}
+		if len(m.ClientURLs) == 0 {
+			es.Error = "member has not started"
+			status.Problems = append(status.Problems, fmt.Sprintf("member %x has not started", m.ID))
+		} else {
+			es.Endpoint = m.ClientURLs[0]
+		}
+		query = append(query, es)
+	}
+	for _, ep := range endpoints {
+		query = append(query, EndpointStatus{Endpoint: ep})
+	}
+
+	leaders := make(map[uint64]bool)
+	for _, es := range query {
+		if es.Endpoint == "" {
+			status.Members = append(status.Members, es)
+			continue
+		}
+
+		sctx, cancel := context.WithTimeout(ctx, timeout)
+		start := time.Now()
+		resp, err := cli.Status(sctx, es.Endpoint)
+		es.Latency = time.Since(start)
+		cancel()
+		if err != nil {
+			es.Error = err.Error()
+			status.Problems = append(status.Problems, fmt.Sprintf("%s: %v", es.Endpoint, err))
+			status.Members = append(status.Members, es)
+			continue
+		}
+
+		es.MemberID = resp.Header.MemberId
+		if m := byID[es.MemberID]; m != nil {
+			es.Name, es.Learner = m.Name, m.IsLearner
+		}
+		es.Alarms = alarms[es.MemberID]
+		es.Version = resp.Version
+		es.DBSize, es.DBSizeInUse = resp.DbSize, resp.DbSizeInUse
+		es.Leader, es.IsLeader = resp.Leader, resp.Leader == resp.Header.MemberId
+		es.RaftTerm, es.RaftIndex = resp.RaftTerm, resp.RaftIndex
+		if len(resp.Errors) > 0 {
+			es.Error = strings.Join(resp.Errors, "; ")
+			status.Problems = append(status.Problems, fmt.Sprintf("%s reports %s", es.Endpoint, es.Error))
+		}
+		leaders[resp.Leader] = true
+		status.Members = append(status.Members, es)
+	}
+
+	switch {
+	case len(leaders) == 1 && !leaders[0]:
+		for id := range leaders {
+			status.Leader = id
+		}
+	case len(leaders) > 1:
+		status.Problems = append(status.Problems, "members disagree on the leader")
+	default:
+		status.Problems = append(status.Problems, "no leader")
+	}
+	return status, nil
+}
+
+// Revision returns the current revision of the cluster.
+func Revision(ctx context.Context, cli *clientv3.Client) (int64, error) {
+	resp, err := cli.Get(ctx, "\x00", clientv3.WithCountOnly())
+	if err != nil {
+		return 0, err
+	}
+	return resp.Header.Revision, nil
+}
+
+// Compact discards the history before rev, so that rev is the oldest
+// revision left. It waits for every member to have removed the old revisions
+// from its database, after which the freed space shows in DBSizeInUse and
+// Defragment can give it back to the filesystem.
+func Compact(ctx context.Context, cli *clientv3.Client, rev int64) error {
+	_, err := cli.Compact(ctx, rev, clientv3.WithCompactPhysical())
+	if errors.Is(err, rpctypes.ErrCompacted) {
+		return fmt.Errorf("%w: the cluster is already compacted at or after revision %d", ErrCompacted, rev)
+	}
+	return err
+}
+
+// Defragment rewrites the database of the member behind endpoint to give
+// the space freed by compaction back to the filesystem. The member serves no
+// requests while it runs.
+func Defragment(ctx context.Context, cli *clientv3.Client, endpoint string) error {
+	_, err := cli.Defragment(ctx, endpoint)
+	return err
+}
@@ -0,0 +1,147 @@
+package walker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// MoveOptions controls Move.
+type MoveOptions struct {
+	Prefix    bool // Move every key under src rather than the single key
+	Overwrite bool // Replace existing destination keys instead of failing
+	ChunkSize int  // Keys per transaction, DefaultTxnOps/2 when zero
+}
+
+// MoveReport summarises a Move. When a chunk fails, the keys before it have
+// been moved and the rest are untouched. Move returns no report when its
+// arguments are rejected before anything is read.
+type MoveReport struct {
+	Revision int64  `json:"revision"` // Revision the source was read at
+	Moved    int    `json:"moved"`
+	Failed   string `json:"failed,omitempty"` // Why the first unmoved chunk failed
+}
+
+// Move renames src to dst, or with Prefix every key under src to the same
+// suffix under dst. Keys are read at one revision and moved in transactions
+// that put the new key and delete the old one, guarded so that a chunk is
+// only applied if none of its source keys changed since that revision and,
+// unless Overwrite, none of its destinations exist. Each transaction uses two
+// compares and two operations per key, which is why chunks hold half of
+// DefaultTxnOps keys. fn is called for every key once its chunk is committed.
+func Move(ctx context.Context, cli *clientv3.Client, src, dst string, opts MoveOptions, fn func(from, to string) error) (*MoveReport, error) {
+	if opts.Prefix && (strings.HasPrefix(dst, src) || strings.HasPrefix(src, dst)) {
+		return nil, fmt.Errorf("destination prefix '%s' overlaps source prefix '%s'", dst, src)
+	}
+	if src == dst {
+		return nil, fmt.Errorf("source and destination are the same")
+	}
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultTxnOps / 2
+	}
+
+	report := &MoveReport{}
+	dstKey := func(kv *mvccpb.KeyValue) string {
+		return dst + strings.TrimPrefix(string(kv.Key), src)
+	}
+
+	var chunk []*mvccpb.KeyValue
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		if err := moveChunk(ctx, cli, chunk, dstKey, opts.Overwrite, report.Revision); err != nil {
+			return err
+		}
+		report.Moved += len(chunk)
+		for _, kv := range chunk {
+			if err := fn(string(kv.Key), dstKey(kv)); err != nil {
+				return err
+			}
+		}
+		chunk = chunk[:0]
+		return nil
+	}
+
+	var err error
+	if opts.Prefix {
+		cur := NewCursor(cli, src, ScanOptions{})
+		for {
+			var kv *mvccpb.KeyValue
+			if kv, err = cur.Next(ctx); err != nil || kv == nil {
+				break
+			}
+			report.Revision = cur.Revision()
+			if chunk = append(chunk, kv); len(chunk) == chunkSize {
+				if err = flush(); err != nil {
+					break
+				}
+			}
+		}
+	} else {
+		var resp *clientv3.GetResponse
+		if resp, err = cli.Get(ctx, src); err == nil {
+			report.Revision = resp.Header.Revision
+			if len(resp.Kvs) == 0 {
+				err = fmt.Errorf("%w: '%s'", ErrNotFound, src)
+			}
+			chunk = resp.Kvs
+		}
+	}
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		report.Failed = err.Error()
+		return report, err
+	}
+	return report, nil
+}
+
+// moveChunk applies one chunk in a single transaction. If a guard fails it
+// looks the keys up again to say which one.
+func moveChunk(ctx context.Context, cli *clientv3.Client, chunk []*mvccpb.KeyValue, dstKey func(*mvccpb.KeyValue) string, overwrite bool, rev int64) error {
+	var cmps []clientv3.Cmp
+	var ops []clientv3.Op
+	for _, kv := range chunk {
+		key := string(kv.Key)
+		cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(key), "=", kv.ModRevision))
+		if !overwrite {
+			cmps = append(cmps, clientv3.Compare(clientv3.CreateRevision(dstKey(kv)), "=", 0))
+		}
+		// Leases are kept so that moved keys still expire
+		ops = append(ops, clientv3.OpPut(dstKey(kv), string(kv.Value), clientv3.WithLease(clientv3.LeaseID(kv.Lease))), clientv3.OpDelete(key))
+	}
+
+	resp, err := cli.Txn(ctx).If(cmps...).Then(ops...).Commit()
+	if err != nil {
+		return fmt.Errorf("moving '%s'..'%s': %w", chunk[0].Key, chunk[len(chunk)-1].Key, err)
+	}
+	if resp.Succeeded {
+		return nil
+	}
+
+	// Find the guard that failed
+	var gets []clientv3.Op
+	for _, kv := range chunk {
+		gets = append(gets, clientv3.OpGet(string(kv.Key)), clientv3.OpGet(dstKey(kv)))
+	}
+	check, err := cli.Txn(ctx).Then(gets...).Commit()
+	if err != nil {
+		return fmt.Errorf("moving '%s'..'%s': a key changed concurrently", chunk[0].Key, chunk[len(chunk)-1].Key)
+	}
+	for i, kv := range chunk {
+		cur := check.Responses[2*i].GetResponseRange().Kvs
+		if len(cur) == 0 || cur[0].ModRevision != kv.ModRevision {
+			return fmt.Errorf("'%s' changed after revision %d, not moving it", kv.Key, rev)
+		}
+		if !overwrite && len(check.Responses[2*i+1].GetResponseRange().Kvs) > 0 {
+			return fmt.Errorf("destination '%s' already exists", dstKey(kv))
+		}
+	}
+	return fmt.Errorf("moving '%s'..'%s': a key changed concurrently", chunk[0].Key, chunk[len(chunk)-1].Key)
+}
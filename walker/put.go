@@ -0,0 +1,101 @@
+package walker
+
+import (
+	"context"
+	"fmt"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// PutOptions are the guards and lease for a Put. With no guard set the put
+// is unconditional.
+type PutOptions struct {
+	IfModRevision int64   // Only put if the key's mod revision is this, when positive
+	IfAbsent      bool    // Only put if the key does not exist
+	IfValue       *string // Only put if the key exists with this value
+	LeaseTTL      int64   // Attach the key to a new lease of this many seconds, when positive
+	Lease         int64   // Attach the key to this existing lease, when LeaseTTL is not set
+}
+
+// PutResult describes a successful Put.
+type PutResult struct {
+	Revision int64            `json:"revision"`        // Mod revision of the new value
+	Lease    LeaseID          `json:"lease,omitempty"` // Lease the key is attached to
+	Prev     *mvccpb.KeyValue `json:"-"`               // Value that was replaced, nil when created
+}
+
+// ConflictError is returned when a Put guard does not hold. Current is the
+// key as it is now, nil when it does not exist.
+type ConflictError struct {
+	Key     string
+	Current *mvccpb.KeyValue
+}
+
+func (e *ConflictError) Error() string {
+	if e.Current == nil {
+		return "compare failed, the key does not exist"
+	}
+	return fmt.Sprintf("compare failed, the key is at mod revision %d", e.Current.ModRevision)
+}
+
+// Put writes value to key in a single transaction whose compares are the
+// guards in opts, so it never replaces a value a concurrent writer put after
+// the caller read it. When a guard fails the key is read back in the same
+// transaction and returned in a *ConflictError. A lease requested with
+// LeaseTTL is revoked again if nothing was written.
+func Put(ctx context.Context, cli *clientv3.Client, key string, value []byte, opts PutOptions) (*PutResult, error) {
+	var cmps []clientv3.Cmp
+	if opts.IfModRevision > 0 {
+		cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(key), "=", opts.IfModRevision))
+	}
+	if opts.IfAbsent {
+		cmps = append(cmps, clientv3.Compare(clientv3.CreateRevision(key), "=", 0))
+	}
+	if opts.IfValue != nil {
+		// A value compare on a missing key fails, which is what we want
+		cmps = append(cmps, clientv3.Compare(clientv3.Value(key), "=", *opts.IfValue))
+	}
+
+	putOpts := []clientv3.OpOption{clientv3.WithPrevKV()}
+	var lease clientv3.LeaseID
+	if opts.LeaseTTL > 0 {
+		grant, err := cli.Grant(ctx, opts.LeaseTTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to grant a %ds lease: %w", opts.LeaseTTL, err)
+		}
+		lease = grant.ID
+		putOpts = append(putOpts, clientv3.WithLease(lease))
+	} else if opts.Lease != 0 {
+		putOpts = append(putOpts, clientv3.WithLease(clientv3.LeaseID(opts.Lease)))
+	}
+
+	resp, err := cli.Txn(ctx).If(cmps...).
+		Then(clientv3.OpPut(key, string(value), putOpts...)).
+		Else(clientv3.OpGet(key)).
+		Commit()
+	if err != nil || !resp.Succeeded {
+		if lease != 0 {
+			cli.Revoke(ctx, lease)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Succeeded {
+		conflict := &ConflictError{Key: key}
+		if kvs := resp.Responses[0].GetResponseRange().Kvs; len(kvs) > 0 {
+			conflict.Current = kvs[0]
+		}
+		return nil, conflict
+	}
+
+	if lease == 0 {
+		lease = clientv3.LeaseID(opts.Lease)
+	}
+	return &PutResult{
+		Revision: resp.Header.Revision,
+		Lease:    LeaseID(lease),
+		Prev:     resp.Responses[0].GetResponsePut().PrevKv,
+	}, nil
+}
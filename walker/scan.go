@@ -0,0 +1,103 @@
+// Package walker implements the etcd operations behind the etcd-walker CLI.
+package walker
+
+import (
+	"context"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// DefaultPageSize is the number of keys fetched per range request when scanning.
+const DefaultPageSize = 500
+
+// ScanOptions controls how Scan pages through a prefix.
+type ScanOptions struct {
+	PageSize int64 // Keys per request, DefaultPageSize when zero
+	Revision int64 // Revision to read at, the revision of the first page when zero
+	KeysOnly bool  // Skip values, for walks that only need key metadata
+}
+
+// Scan calls fn for every key under prefix, in key order, one page at a time.
+// Every page is read at the same revision so the walk is a consistent view of
+// the keyspace even while it is being written to. It returns that revision.
+func Scan(ctx context.Context, cli *clientv3.Client, prefix string, opts ScanOptions, fn func(kv *mvccpb.KeyValue) error) (int64, error) {
+	cur := NewCursor(cli, prefix, opts)
+	for {
+		kv, err := cur.Next(ctx)
+		if err != nil || kv == nil {
+			return cur.Revision(), err
+		}
+		if err := fn(kv); err != nil {
+			return cur.Revision(), err
+		}
+	}
+}
+
+// Cursor pulls the keys under a prefix one at a time, fetching a page when
+// the previous one is used up. Like Scan, all pages share one revision.
+type Cursor struct {
+	cli  *clientv3.Client
+	opts ScanOptions
+	key  string // Start of the next page
+	end  string
+	page []*mvccpb.KeyValue
+	more bool
+}
+
+// NewCursor creates a cursor over prefix. Nothing is read until Next.
+func NewCursor(cli *clientv3.Client, prefix string, opts ScanOptions) *Cursor {
+	if opts.PageSize <= 0 {
+		opts.PageSize = DefaultPageSize
+	}
+	key := prefix
+	if key == "" {
+		key = "\x00" // The empty prefix means the whole keyspace
+	}
+	return &Cursor{cli: cli, opts: opts, key: key, end: clientv3.GetPrefixRangeEnd(prefix), more: true}
+}
+
+// Next returns the next key, or nil when there are none left.
+func (c *Cursor) Next(ctx context.Context) (*mvccpb.KeyValue, error) {
+	for len(c.page) == 0 {
+		if !c.more {
+			return nil, nil
+		}
+		if err := c.fetch(ctx); err != nil {
+			return nil, err
+		}
+	}
+	kv := c.page[0]
+	c.page = c.page[1:]
+	return kv, nil
+}
+
+// Revision is the revision the cursor reads at, known after the first Next.
+func (c *Cursor) Revision() int64 {
+	return c.opts.Revision
+}
+
+func (c *Cursor) fetch(ctx context.Context) error {
+	getOpts := []clientv3.OpOption{clientv3.WithRange(c.end), clientv3.WithLimit(c.opts.PageSize)}
+	if c.opts.Revision > 0 {
+		getOpts = append(getOpts, clientv3.WithRev(c.opts.Revision))
+	}
+	if c.opts.KeysOnly {
+		getOpts = append(getOpts, clientv3.WithKeysOnly())
+	}
+
+	resp, err := c.cli.Get(ctx, c.key, getOpts...)
+	if err != nil {
+		return err
+	}
+	if c.opts.Revision == 0 {
+		c.opts.Revision = resp.Header.Revision
+	}
+
+	c.page = resp.Kvs
+	c.more = resp.More && len(resp.Kvs) > 0
+	if len(resp.Kvs) > 0 {
+		c.key = string(resp.Kvs[len(resp.Kvs)-1].Key) + "\x00"
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package walker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// SnapshotInfo describes a snapshot written by SaveSnapshot.
+type SnapshotInfo struct {
+	Endpoint string
+	MemberID uint64
+	Size     int64
+	SHA256   string // Of the whole file, hex encoded
+}
+
+// SaveSnapshot streams the database of the member behind cli, which must
+// have exactly one endpoint, to path and syncs it to disk. It is written to
+// path.part and renamed once complete, so that an interrupted save never
+// leaves something that looks like a snapshot. An existing path is not
+// replaced.
+func SaveSnapshot(ctx context.Context, cli *clientv3.Client, path string) (*SnapshotInfo, error) {
+	endpoints := cli.Endpoints()
+	if len(endpoints) != 1 {
+		return nil, fmt.Errorf("a snapshot needs a client of exactly one member, not %v", endpoints)
+	}
+	if _, err := os.Lstat(path); err == nil {
+		return nil, fmt.Errorf("%s already exists", path)
+	}
+	status, err := cli.Status(ctx, endpoints[0])
+	if err != nil {
+		return nil, fmt.Errorf("status of %s: %w", endpoints[0], err)
+	}
+
+	part := path + ".part"
+	f, err := os.OpenFile(part, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	info, err := writeSnapshot(ctx, cli, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(part, path)
+	}
+	if err != nil {
+		os.Remove(part)
+		return nil, err
+	}
+	// The rename itself only lasts once the directory is synced
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
+	info.Endpoint, info.MemberID = endpoints[0], status.Header.MemberId
+	return info, nil
+}
+
+func writeSnapshot(ctx context.Context, cli *clientv3.Client, f *os.File) (*SnapshotInfo, error) {
+	rc, err := cli.Snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stream snapshot: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return nil, err
+	}
+	return &SnapshotInfo{Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
@@ -0,0 +1,70 @@
+package walker
+
+import (
+	"sort"
+	"strings"
+)
+
+// Node is one level of the keyspace hierarchy, where levels are the
+// "/"-separated segments of keys.
+type Node struct {
+	Name     string
+	Path     string // Full key prefix of this node
+	Keys     int    // Keys at or below this node
+	Bytes    int64  // Value bytes at or below this node
+	KeyBytes int64  // Key bytes at or below this node
+	IsKey    bool   // A key ends exactly at this node
+	Children map[string]*Node
+}
+
+// Sorted returns the children in name order.
+func (n *Node) Sorted() []*Node {
+	children := make([]*Node, 0, len(n.Children))
+	for _, c := range n.Children {
+		children = append(children, c)
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].Name < children[j].Name })
+	return children
+}
+
+// Tree aggregates keys under a prefix into Nodes. Keys deeper than MaxDepth
+// are counted in their ancestor at MaxDepth, so memory stays proportional to
+// the number of nodes shown rather than the number of keys.
+type Tree struct {
+	Root     *Node
+	MaxDepth int // 0 for unlimited
+}
+
+// NewTree creates an empty tree rooted at prefix.
+func NewTree(prefix string, maxDepth int) *Tree {
+	return &Tree{Root: &Node{Name: prefix, Path: prefix}, MaxDepth: maxDepth}
+}
+
+// Add counts a key, which must start with the root prefix.
+func (t *Tree) Add(key string, valueSize int) {
+	rel := strings.TrimPrefix(strings.TrimPrefix(key, t.Root.Path), "/")
+	start := len(key) - len(rel) // Offset of rel's first segment in key
+
+	n := t.Root
+	n.Keys++
+	n.Bytes += int64(valueSize)
+	n.KeyBytes += int64(len(key))
+	for depth := 1; rel != "" && (t.MaxDepth <= 0 || depth <= t.MaxDepth); depth++ {
+		name, rest, _ := strings.Cut(rel, "/")
+		child, ok := n.Children[name]
+		if !ok {
+			child = &Node{Name: name, Path: key[:start+len(name)]}
+			if n.Children == nil {
+				n.Children = make(map[string]*Node)
+			}
+			n.Children[name] = child
+		}
+		child.Keys++
+		child.Bytes += int64(valueSize)
+		child.KeyBytes += int64(len(key))
+		n, rel, start = child, rest, start+len(name)+1
+	}
+	if rel == "" {
+		n.IsKey = true
+	}
+}
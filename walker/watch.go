@@ -0,0 +1,99 @@
+package walker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// WatchOptions controls Watch.
+type WatchOptions struct {
+	StartRevision int64 // First revision to deliver, the next revision when zero
+	PrevKV        bool  // Include each key's previous value in events
+}
+
+// ErrCompacted is returned by Watch when the revision to resume from has
+// been compacted away, so events would be lost.
+var ErrCompacted = errors.New("required revision has been compacted")
+
+// maxWatchBackoff caps the delay between attempts to re-establish a watch.
+const maxWatchBackoff = 10 * time.Second
+
+// Watch streams every change under prefix to fn until ctx is cancelled or
+// fn returns an error. If the watch is interrupted, for instance because the
+// endpoint went away or lost its leader, it is re-established from the
+// revision after the last one delivered, so no event is skipped or repeated.
+func Watch(ctx context.Context, cli *clientv3.Client, prefix string, opts WatchOptions, fn func(*clientv3.Event) error) error {
+	next := opts.StartRevision
+	backoff := time.Second
+	for {
+		from := next
+		err := watchOnce(ctx, cli, prefix, opts.PrevKV, &next, fn)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var stop stopWatch
+		if errors.As(err, &stop) {
+			return stop.err
+		}
+		if next != from {
+			backoff = time.Second // It was making progress, so retry promptly
+		}
+
+		log.Printf("Watch interrupted (%v), resuming from revision %d in %v", err, next, backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxWatchBackoff)
+	}
+}
+
+// stopWatch marks errors that end Watch rather than cause a reconnect.
+type stopWatch struct{ err error }
+
+func (e stopWatch) Error() string { return e.err.Error() }
+
+// watchOnce runs a single watch from *next, advancing *next as revisions are
+// delivered, until the watch channel closes.
+func watchOnce(ctx context.Context, cli *clientv3.Client, prefix string, prevKV bool, next *int64, fn func(*clientv3.Event) error) error {
+	// Without a leader the member may be partitioned and silently miss events
+	wctx, cancel := context.WithCancel(clientv3.WithRequireLeader(ctx))
+	defer cancel()
+
+	wopts := []clientv3.OpOption{clientv3.WithPrefix(), clientv3.WithCreatedNotify(), clientv3.WithProgressNotify()}
+	if *next > 0 {
+		wopts = append(wopts, clientv3.WithRev(*next))
+	}
+	if prevKV {
+		wopts = append(wopts, clientv3.WithPrevKV())
+	}
+
+	for resp := range cli.Watch(wctx, prefix, wopts...) {
+		if resp.CompactRevision != 0 {
+			return stopWatch{fmt.Errorf("%w: resume from revision %d, oldest available is %d", ErrCompacted, *next, resp.CompactRevision)}
+		}
+		if err := resp.Err(); err != nil {
+			return err
+		}
+
+		for _, ev := range resp.Events {
+			if err := fn(ev); err != nil {
+				return stopWatch{err}
+			}
+			*next = ev.Kv.ModRevision + 1
+		}
+		// A watch from "now" starts after the created header's revision.
+		// Progress notifications are only sent once the watcher has caught
+		// up, so they vouch for everything up to their header revision.
+		if (resp.Created && *next == 0) || (resp.IsProgressNotify() && resp.Header.Revision >= *next) {
+			*next = resp.Header.Revision + 1
+		}
+	}
+	return errors.New("watch channel closed")
+}